@@ -0,0 +1,77 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// WithAnalyzeWeights sets the weights Client.Analyze uses to combine the predictions of the text and
+// the Like IDs. The default is to weight both equally.
+func WithAnalyzeWeights(text, likes float64) ClientOption {
+	return func(c *Client) error {
+		if text <= 0 || likes <= 0 {
+			return fmt.Errorf("analyze weights must be positive")
+		}
+		c.textWeight = text
+		c.likesWeight = likes
+		return nil
+	}
+}
+
+// Analyze predicts the traits of a person based on a writing sample and a set of Like IDs. Both
+// endpoints are queried concurrently and the values of traits predicted by both are combined to a
+// weighted mean (see WithAnalyzeWeights and MergePredictions). Traits predicted by only one endpoint
+// are passed through unweighted.
+//
+// The options are used for both calls, with the source of the text call set to textSource,
+// OptionsSource only sent with the text and OptionsContributors only with the Like IDs. Without options (nil), each call uses its defaults
+// (see WithDefaultTextOptions and WithDefaultLikeOptions). If only one of the calls fails, the result
+// of the other one is returned with Predictions.Partial set. An error is only returned if both calls
+// fail; it wraps the errors of both.
+func (c *Client) Analyze(ctx context.Context, text string, textSource string, ids []string, options url.Values, auth *Token) (predictions Predictions, err error) {
+	textOptions := cloneValues(withDefaults(options, c.defaultTextOptions))
+	textOptions.Set(OptionsSource, textSource)
+	textOptions.Del(OptionsContributors)
+	likeOptions := cloneValues(withDefaults(options, c.defaultLikeOptions))
+	likeOptions.Del(OptionsSource)
+
+	var textPredictions, likePredictions Predictions
+	var textErr, likeErr error
+
+	// Both calls may renew the token, so each of them gets its own copy, as in PredictTexts.
+	tokens := []*Token{copyToken(auth), copyToken(auth)}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		textPredictions, textErr = c.PredictText(ctx, text, textOptions, tokens[0])
+	}()
+	go func() {
+		defer wg.Done()
+		likePredictions, likeErr = c.PredictLikeIDs(ctx, ids, likeOptions, tokens[1])
+	}()
+	wg.Wait()
+	adoptRenewedToken(auth, tokens)
+
+	switch {
+	case textErr != nil && likeErr != nil:
+		return predictions, errors.Join(
+			fmt.Errorf("could not predict text: %w", textErr),
+			fmt.Errorf("could not predict likes: %w", likeErr),
+		)
+	case textErr != nil:
+		likePredictions.Partial = true
+		return likePredictions, nil
+	case likeErr != nil:
+		textPredictions.Partial = true
+		return textPredictions, nil
+	}
+
+	return MergePredictions(
+		WeightedPrediction{Predictions: textPredictions, Weight: c.textWeight},
+		WeightedPrediction{Predictions: likePredictions, Weight: c.likesWeight},
+	), nil
+}
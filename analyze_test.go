@@ -0,0 +1,143 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name        string
+		textStatus  int
+		likeStatus  int
+		wantValue   float64
+		wantPartial bool
+		wantErrs    []error
+	}{
+		{"both", http.StatusOK, http.StatusOK, 0.5, false, nil},
+		{"text failed", http.StatusBadRequest, http.StatusOK, 0.75, true, nil},
+		{"likes failed", http.StatusOK, http.StatusBadRequest, 0.25, true, nil},
+		{"both failed", http.StatusBadRequest, http.StatusForbidden, 0, false, []error{ErrTokenExpired}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				status, value := test.likeStatus, `0.75`
+				if r.URL.Path == EndpointText {
+					status, value = test.textStatus, `0.25`
+				}
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(`{"input_used":1,"predictions":[{"trait":"BIG5_Openness","value":` + value + `}]}`))
+				}
+			}, WithAutoRenew(false))
+
+			predictions, err := client.Analyze(context.Background(), "text", SourceOther, []string{"1"}, nil, StubToken())
+			if test.wantErrs != nil {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Errorf("err = %v, want it to wrap an *APIError", err)
+				}
+				for _, want := range test.wantErrs {
+					if !errors.Is(err, want) {
+						t.Errorf("err = %v, want it to wrap %v", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if value, _ := predictions.Value(TraitOpenness); value != test.wantValue {
+				t.Errorf("openness = %v, want %v", value, test.wantValue)
+			}
+			if predictions.Partial != test.wantPartial {
+				t.Errorf("Partial = %v, want %v", predictions.Partial, test.wantPartial)
+			}
+		})
+	}
+}
+
+// TestAnalyzeRenewal is meant to be run with -race: both calls are rejected with the same token at
+// the same time.
+func TestAnalyzeRenewal(t *testing.T) {
+	var authCalls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			atomic.AddInt32(&authCalls, 1)
+			w.Write([]byte(`{"token":"renewed","customer_id":1,"expires":7258118400000}`))
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "renewed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithAutoRenew(true))
+
+	auth := StubToken()
+	auth.CustomerID, auth.apiKey = 1, "key"
+	predictions, err := client.Analyze(context.Background(), "text", SourceOther, []string{"1"}, nil, auth)
+	if err != nil || predictions.Partial {
+		t.Fatalf("Analyze = %v, %v, want both calls to succeed", predictions, err)
+	}
+	if authCalls != 1 {
+		t.Errorf("token renewed %d times, want once", authCalls)
+	}
+	if auth.Token != "renewed" {
+		t.Errorf("auth.Token = %q, want the renewed token", auth.Token)
+	}
+}
+
+func TestAnalyzeOptions(t *testing.T) {
+	options := PredictTextOptions(SourceEmail, []string{TraitOpenness}, false)
+	options.Set(OptionsContributors, "true")
+
+	var mu sync.Mutex
+	queries := make(map[string]url.Values)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries[r.URL.Path] = r.URL.Query()
+		mu.Unlock()
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithDefaultLikeOptions(PredictLikeIDsOptions([]string{TraitAge}, false, false)))
+
+	for _, options := range []url.Values{options, nil} {
+		if _, err := client.Analyze(context.Background(), "text", SourceTweet, []string{"1"}, options, StubToken()); err != nil {
+			t.Fatal(err)
+		}
+		text, likes := queries[EndpointText], queries[EndpointLikeIDs]
+		if text.Get(OptionsSource) != SourceTweet || text.Has(OptionsContributors) {
+			t.Errorf("options %v: text sent with %v", options, text)
+		}
+		if likes.Has(OptionsSource) {
+			t.Errorf("options %v: likes sent with %v, want no source", options, likes)
+		}
+		if options == nil && likes.Get(OptionsTraits) != TraitAge {
+			t.Errorf("likes sent with %v, want the default options", likes)
+		}
+		if options != nil && (likes.Get(OptionsTraits) != TraitOpenness || likes.Get(OptionsContributors) != "true") {
+			t.Errorf("likes sent with %v, want the options of the call", likes)
+		}
+	}
+	if got := options.Get(OptionsSource); got != SourceEmail {
+		t.Errorf("options of the caller modified: source %q", got)
+	}
+}
+
+func TestAnalyzeDryRun(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request to %s in a dry run", r.URL.Path)
+	}, WithDryRun(true))
+
+	predictions, err := client.Analyze(context.Background(), "text", SourceOther, []string{"1"}, nil, nil)
+	if err != nil || predictions.Source != SourceDryRun {
+		t.Errorf("Analyze = %v, %v, want a dry run", predictions.Source, err)
+	}
+}
@@ -4,24 +4,64 @@
 package applymagicsauce
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-const apiURL = "https://api.applymagicsauce.com"
+// Base URLs of the available environments of the API. See WithEnvironment.
+const (
+	ProductionURL = "https://api.applymagicsauce.com"
+	StagingURL    = "https://api-staging.applymagicsauce.com"
+)
+
+const apiURL = ProductionURL
 
 // APIKey is an optional place to set your APIKey. Normally a call to a prediction endpoint with an
 // expired token will fail. However, if you set APIKey this package will try to renew your token
-// automatically.
+// automatically. Tokens returned by Auth are renewed with the key they were obtained with, APIKey is
+// only used for tokens from elsewhere. A Client can turn this off with WithAutoRenew(false).
+//
+// Deprecated: Use SetAPIKey. The variable is read by every call that authenticates or renews a token,
+// so assigning it while calls are running is a data race. It is still honoured as long as SetAPIKey
+// has not been called.
 var APIKey string
 
+var (
+	apiKeyMu     sync.RWMutex
+	apiKeySet    bool
+	sharedAPIKey string
+)
+
+// SetAPIKey sets the API key used to renew tokens, see APIKey. Unlike assigning APIKey, it is safe to
+// call while other goroutines make calls. Once it has been called, the APIKey variable is ignored.
+func SetAPIKey(key string) {
+	apiKeyMu.Lock()
+	defer apiKeyMu.Unlock()
+	sharedAPIKey, apiKeySet = key, true
+}
+
+// globalAPIKey returns the key set with SetAPIKey, or APIKey if SetAPIKey was never called.
+func globalAPIKey() string {
+	apiKeyMu.RLock()
+	defer apiKeyMu.RUnlock()
+	if apiKeySet {
+		return sharedAPIKey
+	}
+	return APIKey
+}
+
+// DefaultTimeout is the timeout for every request made by the package-level functions (Auth,
+// PredictLikeIDs and PredictText), including reading the response. It is a global setting, so change
+// it before making any calls. Use a Client to configure the timeout independently (see
+// WithDefaultDeadline).
+var DefaultTimeout = 30 * time.Second
+
 // Valid keys for the options parameter in the calls to predict functions (PredictLikeIDs or PredictText).
 const (
 	OptionsSource          = "source"
@@ -43,8 +83,8 @@ const (
 
 // Token represents the response of the API to the Authentication endpoint.
 //
-// It looks like they do not use any of the supported RFCs for the "expires" field.
-// I have not yet figured out how to parse that field into time.Time, so returning it as int for now.
+// The "expires" field is a unix timestamp in milliseconds. It is kept as int in Expires; use ExpiresAt
+// or IsExpired to work with it as time.Time.
 //
 // From documentation:
 // "expires": [timestamp when the token expires, integer]
@@ -56,6 +96,15 @@ type Token struct {
 	Expires     int      `json:"expires"`
 	Permissions []string `json:"permissions"`
 	UsageLimits []Limits `json:"usage_limits"`
+
+	// DryRun is set if the Token was not issued by the API, but returned by a Client with WithDryRun.
+	DryRun bool `json:"-"`
+
+	// expiresAt is Expires parsed by UnmarshalJSON.
+	expiresAt time.Time
+
+	// apiKey is the API key the Token was obtained with by Auth. It is used to renew the Token.
+	apiKey string
 }
 
 // Limits represents the limitations for a Token for the given Method.
@@ -75,66 +124,137 @@ type Limits struct {
 }
 
 // Auth uses the passed customerID and apiKey (obtained during registration on https://applymagicsauce.com)
-// to get a valid authentication token.
+// to get a valid authentication token. If apiKey is empty, APIKey is used instead. A customerID that is
+// not positive or a missing key result in ErrInvalidCredentials.
 func Auth(customerID int, apiKey string) (authToken *Token, err error) {
-	if apiKey == "" && APIKey != "" {
-		apiKey = APIKey
-	}
+	return packageClient().Auth(context.Background(), customerID, apiKey)
+}
 
-	payload := struct {
-		CustomerID int    `json:"customer_id"`
-		APIKey     string `json:"api_key"`
-	}{
-		CustomerID: customerID,
-		APIKey:     apiKey,
-	}
+// Predictions represents the result of your call to one of the prediction endpoints (PredictLikeIDs or
+// PredictText).
+type Predictions struct {
+	InputUsed int `json:"input_used"`
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
+	Predictions     []PredictionEntry     `json:"predictions"`
+	Interpretations []InterpretationEntry `json:"interpretations"`
+	Contributors    []ContributorEntry    `json:"contributors"`
+
+	// ModelVersion identifies the model that made the predictions, if the API reports it. The API
+	// does not document the field, so besides "model_version" the names in modelVersionFields are
+	// accepted as well. It is empty if the response has none of them.
+	ModelVersion string `json:"model_version,omitempty"`
+
+	// Source tells where the Predictions came from. It is never sent by the API.
+	Source ResultSource `json:"-"`
+
+	// Stale is set if the Predictions were taken from the cache because the API was not available
+	// (Source is SourceStale). It is never sent by the API. See WithStaleOnError.
+	Stale bool `json:"-"`
+
+	// Partial is set if the Predictions combine several calls and some of them failed. It is never
+	// sent by the API. See Client.Analyze.
+	Partial bool `json:"-"`
+
+	// ContributorsDropped is set if contributors were requested, but not sent to the API because there
+	// were too few Like IDs. It is never sent by the API. See WithMinContributorIDs.
+	ContributorsDropped bool `json:"-"`
+
+	// Submitted is the number of Like IDs sent to the API. It is set by PredictLikeIDs and never sent
+	// by the API. See Coverage.
+	Submitted int `json:"-"`
+
+	// Requested are the traits requested with OptionsTraits. It is set by PredictLikeIDs and
+	// PredictText and never sent by the API. See MissingTraits.
+	Requested []string `json:"-"`
+
+	// InputRef identifies the input the Predictions were made for, so that results can be matched to
+	// their inputs, e.g. when processing many of them. It is set by PredictLikeIDs and PredictText and
+	// never sent by the API.
+	InputRef InputRef `json:"-"`
+
+	// extra holds the fields of the response that are not decoded into any of the fields above. See
+	// Extra.
+	extra map[string]json.RawMessage
+
+	// rawInterpretations holds the values of Interpretations as they were sent by the API, by trait.
+	// See InterpretationRaw.
+	rawInterpretations map[string]json.RawMessage
+}
+
+// InputRef identifies the input of a prediction call. See Predictions.InputRef.
+type InputRef struct {
+	// LikeIDs are the Like IDs sent to PredictLikeIDs.
+	LikeIDs []string
+	// TextHash is the hex encoded SHA-256 hash of the text sent to PredictText, after
+	// WithTextPreprocessor has been applied.
+	TextHash string
+}
 
-	status, body, err := doRequest("/auth", bytes.NewReader(payloadJSON), nil)
-	if err != nil {
-		return nil, err
+// Coverage returns the share of the totalSubmitted inputs that were used for the predictions
+// (InputUsed / totalSubmitted). A low coverage means that the predictions rest on only a few inputs
+// and may not be reliable. If totalSubmitted is zero or less, the number recorded in Submitted is used
+// instead. Without any submitted inputs the coverage is zero.
+func (p Predictions) Coverage(totalSubmitted int) float64 {
+	if totalSubmitted <= 0 {
+		totalSubmitted = p.Submitted
 	}
+	if totalSubmitted <= 0 {
+		return 0
+	}
+	return float64(p.InputUsed) / float64(totalSubmitted)
+}
+
+// ResultSource tells where a result came from.
+type ResultSource int
 
-	switch status {
-	case http.StatusBadRequest:
-		return nil, fmt.Errorf("bad request: %s", body)
-	case http.StatusForbidden:
-		return nil, fmt.Errorf("authentication failure")
-	case http.StatusNotFound:
-		return nil, fmt.Errorf("endpoint not found")
-	case http.StatusInternalServerError:
-		return nil, fmt.Errorf("api is temporarily not available")
+// Possible values for Predictions.Source.
+const (
+	// SourceLive means the result was returned by the API for this call.
+	SourceLive ResultSource = iota
+	// SourceCache means the result was taken from the cache (see WithCache), possibly after having
+	// been revalidated with the API.
+	SourceCache
+	// SourceStale means the result was taken from the cache because the API was not available (see
+	// WithStaleOnError).
+	SourceStale
+	// SourceDryRun means the call was not sent to the API at all (see WithDryRun).
+	SourceDryRun
+)
+
+func (s ResultSource) String() string {
+	switch s {
+	case SourceLive:
+		return "live"
+	case SourceCache:
+		return "cache"
+	case SourceStale:
+		return "stale"
+	case SourceDryRun:
+		return "dry run"
+	default:
+		return fmt.Sprintf("ResultSource(%d)", int(s))
 	}
+}
 
-	authToken = new(Token)
-	err = json.Unmarshal(body, authToken)
-	return authToken, err
+// PredictionEntry is the predicted value for a single trait.
+type PredictionEntry struct {
+	Trait string  `json:"trait"`
+	Value float64 `json:"value"`
 }
 
-// Predictions represents the result of your call to one of the prediction endpoints (PredictLikeIDs or
-// PredictText).
-type Predictions struct {
-	InputUsed int `json:"input_used"`
+// InterpretationEntry is the interpretation of the prediction for a single trait. The type of Value
+// depends on the trait.
+type InterpretationEntry struct {
+	Trait string      `json:"trait"`
+	Value interface{} `json:"value"`
+}
 
-	Predictions []struct {
-		Trait string  `json:"trait"`
-		Value float64 `json:"value"`
-	} `json:"predictions"`
-
-	Interpretations []struct {
-		Trait string      `json:"trait"`
-		Value interface{} `json:"value"`
-	} `json:"interpretations"`
-
-	Contributors []struct {
-		Trait    string   `json:"trait"`
-		Positive []string `json:"positive"`
-		Negative []string `json:"negative"`
-	} `json:"contributors"`
+// ContributorEntry lists the Like IDs that contributed the most to the prediction of a single trait,
+// in positive and negative direction.
+type ContributorEntry struct {
+	Trait    string   `json:"trait"`
+	Positive []string `json:"positive"`
+	Negative []string `json:"negative"`
 }
 
 // PredictLikeIDs queries the API with the provided Like IDs and returns the corresponding predictions.
@@ -145,52 +265,31 @@ type Predictions struct {
 //
 // You can use the PredictLikeIDsOptions function to get a valid representation of these optional
 // parameters for your call to PredictLikeIDs.
+//
+// If the API has nothing to predict from the Like IDs, the result is empty (see Predictions.IsEmpty)
+// and err is nil.
 func PredictLikeIDs(ids []string, options url.Values, auth *Token) (predictions Predictions, err error) {
-	payloadJSON, err := json.Marshal(ids)
-	if err != nil {
-		return predictions, err
-	}
-
-	status, body, err := doRequest("/like_ids?"+options.Encode(), bytes.NewReader(payloadJSON), auth)
-	if err != nil {
-		return predictions, err
-	}
-
-	switch status {
-	case http.StatusNoContent:
-		return predictions, nil
-	case http.StatusBadRequest:
-		return predictions, fmt.Errorf("bad request: %s", body)
-	case http.StatusNotFound:
-		return predictions, fmt.Errorf("endpoint not found")
-	case http.StatusTooManyRequests:
-		return predictions, fmt.Errorf("usage limit exceeded: %s", body)
-	case http.StatusInternalServerError:
-		return predictions, fmt.Errorf("api is temporarily not available")
-	case http.StatusForbidden:
-		if APIKey != "" {
-			err = renewToken(auth)
-			if err != nil {
-				return predictions, err
-			}
-			return PredictLikeIDs(ids, options, auth)
-		}
-		return predictions, fmt.Errorf("authentication token expired")
-	}
-
-	err = json.Unmarshal(body, &predictions)
-	return predictions, err
+	return packageClient().PredictLikeIDs(context.Background(), ids, options, auth)
 }
 
 // PredictLikeIDsOptions returns a valid options object for use in PredictLikeIDs. All parameters are
-// optional. The zero values represent the default behaviour of the API.
+// optional. The zero values represent the default behaviour of the API, so they are left out of the
+// options.
+//
+// The traits are sorted and duplicates are removed, so the same set of traits always results in the
+// same options (and cache key, see CacheKey). This does not change what the API returns.
+//
+// Not every trait has an interpretation. If you request interpretations for such traits, the API just
+// leaves them out of Predictions.Interpretations.
 func PredictLikeIDsOptions(traits []string, interpretations bool, contributors bool) (options url.Values) {
 	options = url.Values{}
-	if len(traits) > 0 {
-		options.Set(OptionsTraits, strings.Join(traits, ","))
+	setTraits(options, traits)
+	if interpretations {
+		options.Set(OptionsInterpretations, fmt.Sprintf("%t", interpretations))
+	}
+	if contributors {
+		options.Set(OptionsContributors, fmt.Sprintf("%t", contributors))
 	}
-	options.Set(OptionsInterpretations, fmt.Sprintf("%t", interpretations))
-	options.Set(OptionsContributors, fmt.Sprintf("%t", contributors))
 	return options
 }
 
@@ -202,87 +301,108 @@ func PredictLikeIDsOptions(traits []string, interpretations bool, contributors b
 // You can use the PredictTextOptions function to get a valid representation of these optional
 // parameters for your call to PredictText.
 //
-// ATTENTION: Not all options are optional! See PredictTextOptions for details.
+// If the API has nothing to predict from the text, the result is empty (see Predictions.IsEmpty) and
+// err is nil.
+//
+// ATTENTION: Not all options are optional! See PredictTextOptions for details. OptionsContributors is
+// not supported by the text endpoint and never sent (see PredictTextOptionsWithContributors).
 func PredictText(text string, options url.Values, auth *Token) (predictions Predictions, err error) {
-	status, body, err := doRequest("/text?"+options.Encode(), strings.NewReader(text), auth)
-	if err != nil {
-		return predictions, err
-	}
-
-	switch status {
-	case http.StatusBadRequest:
-		return predictions, fmt.Errorf("bad request: %s", body)
-	case http.StatusNotFound:
-		return predictions, fmt.Errorf("endpoint not found")
-	case http.StatusTooManyRequests:
-		return predictions, fmt.Errorf("usage limit exceeded: %s", body)
-	case http.StatusInternalServerError:
-		return predictions, fmt.Errorf("api is temporarily not available")
-	case http.StatusForbidden:
-		if APIKey != "" {
-			err = renewToken(auth)
-			if err != nil {
-				return predictions, err
-			}
-			return PredictText(text, options, auth)
-		}
-		return predictions, fmt.Errorf("authentication token expired")
-	}
-
-	err = json.Unmarshal(body, &predictions)
-	return predictions, err
+	return packageClient().PredictText(context.Background(), text, options, auth)
 }
 
 // PredictTextOptions returns a valid options object for use in PredictText. The source parameter is
 // required. All other parameters are optional and the zero values represent the default behaviour
-// of the API.
+// of the API, so they are left out of the options.
+//
+// The text endpoint does not support OptionsContributors. See PredictLikeIDsOptions for the handling
+// of traits and the behaviour of interpretations.
 func PredictTextOptions(source string, traits []string, interpretations bool) (options url.Values) {
 	options = url.Values{}
 	options.Set(OptionsSource, source)
-	if len(traits) > 0 {
-		options.Set(OptionsTraits, strings.Join(traits, ","))
+	setTraits(options, traits)
+	if interpretations {
+		options.Set(OptionsInterpretations, fmt.Sprintf("%t", interpretations))
 	}
-	options.Set(OptionsInterpretations, fmt.Sprintf("%t", interpretations))
 	return options
 }
 
-func doRequest(endpoint string, payload io.Reader, auth *Token) (statusCode int, body []byte, err error) {
-	request, err := http.NewRequest(http.MethodPost, apiURL+endpoint, payload)
-	if err != nil {
-		return 0, nil, err
+// PredictTextOptionsWithContributors works like PredictTextOptions, but can also request contributors.
+// The text endpoint does not support contributors yet, so until it does a Client rejects such options
+// with ErrContributorsNotSupported (and the package-level PredictText drops them). It exists so that
+// code can be prepared for the time the API adds support.
+func PredictTextOptionsWithContributors(source string, traits []string, interpretations bool, contributors bool) (options url.Values) {
+	options = PredictTextOptions(source, traits, interpretations)
+	if contributors {
+		options.Set(OptionsContributors, fmt.Sprintf("%t", contributors))
 	}
+	return options
+}
 
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Accept", "application/json")
-	if auth != nil {
-		request.Header.Set("X-Auth-Token", auth.Token)
-	}
+// MinimalBigFiveOptions returns options for PredictText that request only the Big Five traits without
+// interpretations. This is the fastest way to get a personality profile from a text.
+func MinimalBigFiveOptions(source string) (options url.Values) {
+	return PredictTextOptions(source, bigFiveTraits, false)
+}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+// MinimalBigFiveLikeOptions returns options for PredictLikeIDs that request only the Big Five traits
+// without interpretations and contributors. This is the fastest way to get a personality profile from
+// Like IDs.
+func MinimalBigFiveLikeOptions() (options url.Values) {
+	return PredictLikeIDsOptions(bigFiveTraits, false, false)
+}
 
-	response, err := client.Do(request)
-	if err != nil {
-		return 0, nil, err
-	}
-	defer response.Body.Close()
+// TraitsEncoding defines how the option builders encode the traits in OptionsTraits.
+type TraitsEncoding int
 
-	body, err = ioutil.ReadAll(response.Body)
+// Possible values for DefaultTraitsEncoding.
+const (
+	// TraitsJoined encodes the traits as a single comma-separated value ("traits=A,B"). This is what
+	// the API expects.
+	TraitsJoined TraitsEncoding = iota
+	// TraitsRepeated encodes every trait as a value of its own ("traits=A&traits=B").
+	TraitsRepeated
+)
 
-	return response.StatusCode, body, err
-}
+// DefaultTraitsEncoding is the TraitsEncoding of the option builders (e.g. PredictTextOptions). It only
+// needs to be changed if the API changes the way it expects the traits. It is a global setting, so
+// change it before building any options. Both encodings are understood by the predict functions.
+var DefaultTraitsEncoding = TraitsJoined
+
+// setTraits sets OptionsTraits to the canonical form of traits (sorted and without duplicates),
+// encoded according to DefaultTraitsEncoding. Without traits, options are left unchanged.
+func setTraits(options url.Values, traits []string) {
+	if len(traits) == 0 {
+		return
+	}
 
-func renewToken(auth *Token) error {
-	token, err := Auth(auth.CustomerID, APIKey)
-	if err != nil {
-		return fmt.Errorf("could not renew authentication token")
+	sorted := make([]string, len(traits))
+	copy(sorted, traits)
+	sort.Strings(sorted)
+
+	unique := sorted[:0]
+	for i, trait := range sorted {
+		if i == 0 || trait != sorted[i-1] {
+			unique = append(unique, trait)
+		}
 	}
 
-	auth.Expires = token.Expires
-	auth.Permissions = token.Permissions
-	auth.Token = token.Token
-	auth.UsageLimits = token.UsageLimits
+	switch DefaultTraitsEncoding {
+	case TraitsRepeated:
+		options[OptionsTraits] = unique
+	default:
+		options.Set(OptionsTraits, strings.Join(unique, ","))
+	}
+}
 
-	return nil
+// requestedTraits returns the traits in OptionsTraits of options, independent of their encoding.
+func requestedTraits(options url.Values) []string {
+	var traits []string
+	for _, value := range options[OptionsTraits] {
+		for _, trait := range strings.Split(value, ",") {
+			if trait != "" {
+				traits = append(traits, trait)
+			}
+		}
+	}
+	return traits
 }
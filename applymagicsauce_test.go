@@ -0,0 +1,269 @@
+package applymagicsauce
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoverage(t *testing.T) {
+	tests := []struct {
+		name           string
+		predictions    Predictions
+		totalSubmitted int
+		want           float64
+	}{
+		{"full", Predictions{InputUsed: 4}, 4, 1},
+		{"partial", Predictions{InputUsed: 1}, 4, 0.25},
+		{"zero", Predictions{InputUsed: 0}, 4, 0},
+		{"recorded submitted", Predictions{InputUsed: 3, Submitted: 4}, 0, 0.75},
+		{"argument wins", Predictions{InputUsed: 3, Submitted: 4}, 6, 0.5},
+		{"nothing submitted", Predictions{InputUsed: 3}, 0, 0},
+	}
+	for _, test := range tests {
+		if got := test.predictions.Coverage(test.totalSubmitted); got != test.want {
+			t.Errorf("%s: Coverage(%d) = %v, want %v", test.name, test.totalSubmitted, got, test.want)
+		}
+	}
+}
+
+func TestPredictLikeIDsSubmitted(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":2}`))
+	})
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1", "2", "3", "4", "5"}, nil, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if predictions.Submitted != 5 || predictions.Coverage(0) != 0.4 {
+		t.Errorf("Submitted = %d, Coverage(0) = %v, want 5 and 0.4", predictions.Submitted, predictions.Coverage(0))
+	}
+}
+
+func TestResultSource(t *testing.T) {
+	predictors := map[string]func(*Client) (Predictions, error){
+		"PredictText": func(c *Client) (Predictions, error) {
+			return c.PredictText(context.Background(), "text", MinimalBigFiveOptions(SourceOther), StubToken())
+		},
+		"PredictLikeIDs": func(c *Client) (Predictions, error) {
+			return c.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+		},
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	}
+
+	for name, predict := range predictors {
+		cached := newTestClient(t, handler, WithCache(NewMemoryCache()))
+		dryRun := newTestClient(t, handler, WithDryRun(true))
+		for i, want := range []struct {
+			client *Client
+			source ResultSource
+		}{{cached, SourceLive}, {cached, SourceCache}, {dryRun, SourceDryRun}} {
+			predictions, err := predict(want.client)
+			if err != nil {
+				t.Fatalf("%s, call %d: %v", name, i, err)
+			}
+			if predictions.Source != want.source || predictions.Stale {
+				t.Errorf("%s, call %d: Source = %v (stale %t), want %v", name, i, predictions.Source, predictions.Stale, want.source)
+			}
+		}
+	}
+
+	for source, want := range map[ResultSource]string{SourceLive: "live", SourceStale: "stale", SourceDryRun: "dry run", 9: "ResultSource(9)"} {
+		if source.String() != want {
+			t.Errorf("String() = %q, want %q", source.String(), want)
+		}
+	}
+}
+
+func TestInputRef(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithCache(NewMemoryCache()))
+	ctx := context.Background()
+
+	texts := []string{"first", "second", "third", "second"}
+	results, err := client.PredictTexts(ctx, texts, MinimalBigFiveOptions(SourceOther), StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range texts {
+		hash := sha256.Sum256([]byte(text))
+		if got := results[i].InputRef; got.TextHash != hex.EncodeToString(hash[:]) || got.LikeIDs != nil {
+			t.Errorf("InputRef of text %d = %+v, want the hash of %q", i, got, text)
+		}
+	}
+
+	// Results from the cache refer to the input of the call as well.
+	for _, ids := range [][]string{{"1", "2"}, {"1", "2"}, {"3"}} {
+		predictions, err := client.PredictLikeIDs(ctx, ids, nil, StubToken())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(predictions.InputRef, InputRef{LikeIDs: ids}) {
+			t.Errorf("InputRef = %+v, want %v", predictions.InputRef, ids)
+		}
+		ids[0] = "changed"
+		if predictions.InputRef.LikeIDs[0] == "changed" {
+			t.Error("InputRef shares the Like IDs of the caller")
+		}
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { DefaultTimeout = timeout }(DefaultTimeout)
+	DefaultTimeout = 50 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The context of the request is only canceled once the body has been read.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	// The package-level functions always talk to ProductionURL, so use their Client directly.
+	client := packageClient()
+	client.baseURL = server.URL
+	start := time.Now()
+	_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("call took %s with a DefaultTimeout of %s", elapsed, DefaultTimeout)
+	}
+}
+
+func TestOptionsEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		options url.Values
+		want    string
+	}{
+		{"likes without flags", PredictLikeIDsOptions([]string{TraitAge}, false, false), "traits=Age"},
+		{"likes with interpretations", PredictLikeIDsOptions([]string{TraitAge}, true, false), "interpretations=true&traits=Age"},
+		{"likes with contributors", PredictLikeIDsOptions(nil, false, true), "contributors=true"},
+		{"text without flags", PredictTextOptions(SourceTweet, nil, false), "source=TWEET"},
+		{"text with interpretations", PredictTextOptions(SourceTweet, []string{TraitAge}, true), "interpretations=true&source=TWEET&traits=Age"},
+	}
+	for _, test := range tests {
+		if got := test.options.Encode(); got != test.want {
+			t.Errorf("%s: %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMinimalBigFiveOptions(t *testing.T) {
+	bigFive := TraitAgreeableness + "," + TraitConscientiousness + "," + TraitExtraversion + "," + TraitNeuroticism + "," + TraitOpenness
+	tests := []struct {
+		name    string
+		options url.Values
+		want    url.Values
+	}{
+		{"text", MinimalBigFiveOptions(SourceCV), url.Values{OptionsSource: {SourceCV}, OptionsTraits: {bigFive}}},
+		{"likes", MinimalBigFiveLikeOptions(), url.Values{OptionsTraits: {bigFive}}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.options, test.want) {
+			t.Errorf("%s: options = %v, want %v", test.name, test.options, test.want)
+		}
+	}
+}
+
+func TestTextContributorsRejected(t *testing.T) {
+	if got, want := PredictTextOptionsWithContributors(SourceTweet, []string{TraitAge}, false, true).Encode(), "contributors=true&source=TWEET&traits=Age"; got != want {
+		t.Errorf("PredictTextOptionsWithContributors = %s, want %s", got, want)
+	}
+	if got, want := PredictTextOptionsWithContributors(SourceTweet, nil, true, false), PredictTextOptions(SourceTweet, nil, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("PredictTextOptionsWithContributors without contributors = %v, want %v", got, want)
+	}
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request with contributors sent to %s", r.URL.Path)
+	})
+	options := PredictTextOptionsWithContributors(SourceTweet, nil, false, true)
+	if _, err := client.PredictText(context.Background(), "text", options, StubToken()); !errors.Is(err, ErrContributorsNotSupported) {
+		t.Errorf("err = %v, want ErrContributorsNotSupported", err)
+	}
+}
+
+func TestTraitsEncoding(t *testing.T) {
+	defer func(encoding TraitsEncoding) { DefaultTraitsEncoding = encoding }(DefaultTraitsEncoding)
+
+	traits := []string{TraitOpenness, TraitAge}
+	tests := []struct {
+		encoding TraitsEncoding
+		want     []string
+	}{
+		{TraitsJoined, []string{TraitAge + "," + TraitOpenness}},
+		{TraitsRepeated, []string{TraitAge, TraitOpenness}},
+	}
+	for _, test := range tests {
+		DefaultTraitsEncoding = test.encoding
+		for name, options := range map[string]url.Values{
+			"PredictTextOptions":    PredictTextOptions(SourceOther, traits, false),
+			"PredictLikeIDsOptions": PredictLikeIDsOptions(traits, false, false),
+		} {
+			if got := options[OptionsTraits]; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("encoding %d: %s traits = %q, want %q", test.encoding, name, got, test.want)
+			}
+			if got := requestedTraits(options); !reflect.DeepEqual(got, []string{TraitAge, TraitOpenness}) {
+				t.Errorf("encoding %d: %s requests %v", test.encoding, name, got)
+			}
+		}
+	}
+}
+
+func TestTraitsCanonical(t *testing.T) {
+	traits := []string{TraitOpenness, TraitAge, TraitOpenness, TraitConscientiousness}
+	want := PredictLikeIDsOptions([]string{TraitAge, TraitConscientiousness, TraitOpenness}, false, false).Encode()
+
+	for _, order := range [][]string{traits, {TraitConscientiousness, TraitOpenness, TraitAge}, {TraitAge, TraitAge, TraitOpenness, TraitConscientiousness}} {
+		if got := PredictLikeIDsOptions(order, false, false).Encode(); got != want {
+			t.Errorf("PredictLikeIDsOptions(%v) = %s, want %s", order, got, want)
+		}
+		text := PredictTextOptions(SourceOther, order, false)
+		if key, wantKey := CacheKey(EndpointText, text, nil), CacheKey(EndpointText, PredictTextOptions(SourceOther, traits, false), nil); key != wantKey {
+			t.Errorf("cache key for %v = %s, want %s", order, key, wantKey)
+		}
+	}
+	if traits[0] != TraitOpenness || len(traits) != 4 {
+		t.Errorf("traits of the caller were modified: %v", traits)
+	}
+}
+
+func TestTextContributorsDropped(t *testing.T) {
+	var query string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"input_used":1}`))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The package-level PredictText drops the contributors the text endpoint does not support.
+	options := url.Values{OptionsSource: {SourceTweet}, OptionsContributors: {"true"}}
+	client := packageClient()
+	client.baseURL = server.URL
+	if _, err := client.PredictText(context.Background(), "text", options, StubToken()); err != nil {
+		t.Fatal(err)
+	}
+	if query != "source=TWEET" {
+		t.Errorf("sent %q, want the options without contributors", query)
+	}
+	if options.Get(OptionsContributors) != "true" {
+		t.Error("the options of the caller were modified")
+	}
+}
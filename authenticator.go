@@ -0,0 +1,235 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenStore stores the Token of an Authenticator, e.g. to share it between processes.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+type TokenStore interface {
+	// Load returns the stored Token and whether there is one.
+	Load() (token *Token, ok bool)
+	// Save stores token, replacing any existing one.
+	Save(token *Token)
+}
+
+// MemoryTokenStore is a TokenStore that keeps the Token in memory.
+type MemoryTokenStore struct {
+	mu    sync.RWMutex
+	token *Token
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Load implements TokenStore.
+func (m *MemoryTokenStore) Load() (token *Token, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.token, m.token != nil
+}
+
+// Save implements TokenStore.
+func (m *MemoryTokenStore) Save(token *Token) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = token
+}
+
+// authenticatorRefreshMargin is how long before its expiry an Authenticator replaces a Token.
+const authenticatorRefreshMargin = time.Minute
+
+// Authenticator hands out valid tokens for a Credential. It authenticates when it is asked for a
+// token for the first time and again shortly before the token expires, so callers never have to
+// deal with the lifecycle of tokens themselves. See WithAuthenticator.
+//
+// An Authenticator is safe for concurrent use by multiple goroutines. Concurrent calls that need a
+// new token share a single call to Auth.
+type Authenticator struct {
+	client     *Client
+	credential Credential
+	store      TokenStore
+
+	onRefreshError func(error)
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// AuthenticatorOption configures an Authenticator.
+type AuthenticatorOption func(*Authenticator)
+
+// WithRefreshErrorHandler sets a function that is called with the error of every failed refresh of
+// StartAutoRefresh, e.g. to raise an alert if the API key has been revoked.
+func WithRefreshErrorHandler(handler func(error)) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.onRefreshError = handler
+	}
+}
+
+// NewAuthenticator returns an Authenticator that authenticates with client. The tokens are kept in
+// store, which may be nil to keep them in memory only.
+func NewAuthenticator(client *Client, credential Credential, store TokenStore, options ...AuthenticatorOption) *Authenticator {
+	if store == nil {
+		store = NewMemoryTokenStore()
+	}
+	a := &Authenticator{
+		client:     client,
+		credential: credential,
+		store:      store,
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// Intervals of the background refresh of StartAutoRefresh.
+const (
+	// autoRefreshUnknownExpiry is the interval at which a token with an unknown expiry is checked.
+	autoRefreshUnknownExpiry = 30 * time.Minute
+	// autoRefreshMinBackoff and autoRefreshMaxBackoff limit the delay after a failed refresh, which
+	// doubles with every consecutive failure.
+	autoRefreshMinBackoff = time.Second
+	autoRefreshMaxBackoff = 5 * time.Minute
+)
+
+// StartAutoRefresh starts a goroutine that refreshes the token in the background shortly before it
+// expires, so that Token rarely has to wait for Auth. Failed refreshes are reported to the handler of
+// WithRefreshErrorHandler and retried with an exponential backoff. The goroutine stops when ctx is
+// done. It waits with the clock and the timers of the Client (see WithClock and WithTimer).
+func (a *Authenticator) StartAutoRefresh(ctx context.Context) {
+	go func() {
+		failures := 0
+		for {
+			wait := a.untilRefresh()
+			if failures > 0 {
+				wait = autoRefreshMinBackoff << uint(failures-1)
+				if wait > autoRefreshMaxBackoff || wait <= 0 {
+					wait = autoRefreshMaxBackoff
+				}
+			}
+
+			expired, stop := a.client.timer(wait)
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case <-expired:
+			}
+
+			if _, err := a.Token(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				failures++
+				if a.onRefreshError != nil {
+					a.onRefreshError(err)
+				}
+				continue
+			}
+			failures = 0
+		}
+	}()
+}
+
+// untilRefresh returns the time until the current token needs to be refreshed.
+func (a *Authenticator) untilRefresh() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == nil {
+		a.token, _ = a.store.Load()
+	}
+	if a.token == nil {
+		return 0
+	}
+	expires := a.token.ExpiresAt()
+	if expires.IsZero() {
+		return autoRefreshUnknownExpiry
+	}
+	if wait := expires.Add(-authenticatorRefreshMargin).Sub(a.client.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Token returns a token that is not expired, authenticating first if necessary. Tokens whose expiry
+// is unknown are used until the API rejects them. The returned Token is shared and must not be
+// modified.
+func (a *Authenticator) Token(ctx context.Context) (*Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == nil {
+		a.token, _ = a.store.Load()
+	}
+	if a.token != nil && a.valid(a.token) {
+		return a.token, nil
+	}
+	return a.refresh(ctx)
+}
+
+// renew returns a new token after the API rejected the token with the value rejected. If another
+// goroutine has renewed it in the meantime, that token is returned instead of authenticating again.
+func (a *Authenticator) renew(ctx context.Context, rejected string) (*Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == nil {
+		a.token, _ = a.store.Load()
+	}
+	if a.token != nil && a.token.Token != rejected && a.valid(a.token) {
+		return a.token, nil
+	}
+	return a.refresh(ctx)
+}
+
+// refresh authenticates and stores the new token. a.mu must be held.
+func (a *Authenticator) refresh(ctx context.Context) (*Token, error) {
+	token, err := a.client.Auth(ctx, a.credential.CustomerID, a.credential.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate customer %d: %w", a.credential.CustomerID, err)
+	}
+	a.token = token
+	a.store.Save(token)
+	return token, nil
+}
+
+func (a *Authenticator) valid(token *Token) bool {
+	expires := token.ExpiresAt()
+	return expires.IsZero() || a.client.now().Add(authenticatorRefreshMargin).Before(expires)
+}
+
+// WithAuthenticator makes the Client get its tokens from authenticator: the predict functions use a
+// token of authenticator whenever they are called with a nil token. Rejected tokens are renewed
+// through authenticator as well, unless WithTokenProvider is set.
+func WithAuthenticator(authenticator *Authenticator) ClientOption {
+	return func(c *Client) error {
+		if authenticator == nil {
+			return fmt.Errorf("authenticator must not be nil")
+		}
+		c.authenticator = authenticator
+		return nil
+	}
+}
+
+// authenticated returns auth, or a copy of a token of the Authenticator if auth is nil. The copy
+// keeps renewals of the call from modifying the shared token.
+func (c *Client) authenticated(ctx context.Context, auth *Token) (*Token, error) {
+	if auth != nil || c.authenticator == nil || c.dryRun {
+		return auth, nil
+	}
+	token, err := c.authenticator.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	copied := *token
+	return &copied, nil
+}
@@ -0,0 +1,235 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for WithClock and WithTimer that only moves when advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at      time.Time
+	expired chan time.Time
+	stopped *bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) options() []ClientOption {
+	return []ClientOption{WithClock(c.Now), WithTimer(c.NewTimer)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := fakeTimer{at: c.now.Add(d), expired: make(chan time.Time, 1), stopped: new(bool)}
+	if d <= 0 {
+		// Like time.NewTimer, a timer without a duration expires right away.
+		timer.expired <- c.now
+	} else {
+		c.timers = append(c.timers, timer)
+	}
+	return timer.expired, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		*timer.stopped = true
+		return true
+	}
+}
+
+// waitForTimer waits until a timer is pending and returns how long it runs.
+func (c *fakeClock) waitForTimer(t *testing.T) time.Duration {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		for _, timer := range c.timers {
+			if !*timer.stopped {
+				c.mu.Unlock()
+				return timer.at.Sub(c.Now())
+			}
+		}
+		c.mu.Unlock()
+	}
+	t.Fatal("no timer started")
+	return 0
+}
+
+// advance moves the clock forward by d and fires the timers that expire.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		switch {
+		case *timer.stopped:
+		case !timer.at.After(c.now):
+			timer.expired <- c.now
+		default:
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+}
+
+func TestAutoRefresh(t *testing.T) {
+	clock := newFakeClock()
+	var authCalls int32
+	authenticate := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&authCalls, 1)
+		expires := clock.Now().Add(10 * time.Minute)
+		w.Write([]byte(`{"token":"token ` + strconv.Itoa(int(n)) + `","customer_id":1,"expires":` + strconv.FormatInt(expires.UnixNano()/1e6, 10) + `}`))
+	}
+	client := newTestClient(t, authenticate, clock.options()...)
+	authenticator := NewAuthenticator(client, Credential{CustomerID: 1, APIKey: "key"}, nil)
+	if _, err := authenticator.Token(context.Background()); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authenticator.StartAutoRefresh(ctx)
+
+	wait := clock.waitForTimer(t)
+	if want := 10*time.Minute - authenticatorRefreshMargin; wait != want {
+		t.Errorf("refresh after %s, want %s", wait, want)
+	}
+	clock.advance(wait)
+
+	clock.waitForTimer(t)
+	if calls := atomic.LoadInt32(&authCalls); calls != 2 {
+		t.Errorf("%d calls to Auth, want 2", calls)
+	}
+	token, err := authenticator.Token(context.Background())
+	if err != nil || token.Token != "token 2" {
+		t.Errorf("Token = %v, %v, want the refreshed token", token, err)
+	}
+}
+
+func TestAutoRefreshBackoff(t *testing.T) {
+	clock := newFakeClock()
+	var failing atomic.Bool
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		expires := clock.Now().Add(10 * time.Minute)
+		w.Write([]byte(`{"token":"t","customer_id":1,"expires":` + strconv.FormatInt(expires.UnixNano()/1e6, 10) + `}`))
+	}, clock.options()...)
+
+	refreshErrors := make(chan error, 10)
+	authenticator := NewAuthenticator(client, Credential{CustomerID: 1, APIKey: "key"}, nil,
+		WithRefreshErrorHandler(func(err error) { refreshErrors <- err }))
+	if _, err := authenticator.Token(context.Background()); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authenticator.StartAutoRefresh(ctx)
+
+	// The key is revoked before the first refresh.
+	failing.Store(true)
+	clock.advance(clock.waitForTimer(t))
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if err := <-refreshErrors; err == nil {
+			t.Error("handler called without an error")
+		}
+		if wait := clock.waitForTimer(t); wait != want {
+			t.Errorf("retry after %s, want %s", wait, want)
+		}
+		clock.advance(want)
+	}
+	<-refreshErrors
+
+	// Once a refresh succeeds, the next one is scheduled by the expiry of the new token again.
+	failing.Store(false)
+	clock.advance(clock.waitForTimer(t))
+	if wait, want := clock.waitForTimer(t), 10*time.Minute-authenticatorRefreshMargin; wait != want {
+		t.Errorf("refresh after a recovery after %s, want %s", wait, want)
+	}
+	select {
+	case err := <-refreshErrors:
+		t.Errorf("handler called after a successful refresh: %v", err)
+	default:
+	}
+}
+
+func TestAuthenticatorConcurrentToken(t *testing.T) {
+	clock := newFakeClock()
+	expiresIn := func(d time.Duration) *Token {
+		return &Token{Token: "stored", CustomerID: 1, Expires: int(clock.Now().Add(d).UnixNano() / 1e6)}
+	}
+	tests := []struct {
+		name      string
+		stored    *Token
+		wantCalls int32
+	}{
+		{"empty store", nil, 1},
+		{"valid token", expiresIn(time.Hour), 0},
+		{"token within the refresh margin", expiresIn(authenticatorRefreshMargin / 2), 1},
+		{"expired token", expiresIn(-time.Hour), 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var authCalls int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&authCalls, 1)
+				time.Sleep(10 * time.Millisecond)
+				w.Write([]byte(`{"token":"new","customer_id":1,"expires":` + strconv.FormatInt(clock.Now().Add(time.Hour).UnixNano()/1e6, 10) + `}`))
+			}, clock.options()...)
+			store := NewMemoryTokenStore()
+			if test.stored != nil {
+				store.Save(test.stored)
+			}
+			authenticator := NewAuthenticator(client, Credential{CustomerID: 1, APIKey: "key"}, store)
+
+			tokens := make([]*Token, 20)
+			var wg sync.WaitGroup
+			for i := range tokens {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					token, err := authenticator.Token(context.Background())
+					if err != nil {
+						t.Errorf("Token: %v", err)
+					}
+					tokens[i] = token
+				}(i)
+			}
+			wg.Wait()
+
+			if authCalls != test.wantCalls {
+				t.Errorf("%d calls to Auth, want %d", authCalls, test.wantCalls)
+			}
+			for _, token := range tokens {
+				if token != tokens[0] {
+					t.Fatalf("Token returned %v and %v, want the same token for all goroutines", tokens[0], token)
+				}
+			}
+			if stored, _ := store.Load(); stored != tokens[0] {
+				t.Errorf("stored token = %v, want %v", stored, tokens[0])
+			}
+		})
+	}
+}
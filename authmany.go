@@ -0,0 +1,49 @@
+package applymagicsauce
+
+import (
+	"context"
+	"sync"
+)
+
+// authManyConcurrency is the maximum number of concurrent requests made by Client.AuthMany.
+const authManyConcurrency = 8
+
+// Credential is a customer ID and the matching API key, as used by Auth.
+type Credential struct {
+	CustomerID int
+	APIKey     string
+}
+
+// AuthMany authenticates all creds concurrently, with at most a handful of requests in flight at a
+// time. All requests share the transport of the Client, so its idle connections are reused.
+//
+// The returned slices are aligned with creds: tokens[i] and errs[i] are the results of Auth for
+// creds[i], and exactly one of them is nil. Credentials that were not yet sent when ctx is done fail
+// with the error of ctx.
+func (c *Client) AuthMany(ctx context.Context, creds []Credential) (tokens []*Token, errs []error) {
+	tokens = make([]*Token, len(creds))
+	errs = make([]error, len(creds))
+
+	semaphore := make(chan struct{}, authManyConcurrency)
+	var wg sync.WaitGroup
+	for i, cred := range creds {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(creds); j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return tokens, errs
+		}
+
+		wg.Add(1)
+		go func(i int, cred Credential) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			tokens[i], errs[i] = c.Auth(ctx, cred.CustomerID, cred.APIKey)
+		}(i, cred)
+	}
+	wg.Wait()
+	return tokens, errs
+}
@@ -0,0 +1,81 @@
+package applymagicsauce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthMany(t *testing.T) {
+	var inFlight, maxInFlight int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		var credentials struct {
+			CustomerID int    `json:"customer_id"`
+			APIKey     string `json:"api_key"`
+		}
+		json.NewDecoder(r.Body).Decode(&credentials)
+		if credentials.APIKey != fmt.Sprint("key", credentials.CustomerID) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"token":"token%d","customer_id":%d}`, credentials.CustomerID, credentials.CustomerID)
+	})
+
+	var creds []Credential
+	for id := 1; id <= 20; id++ {
+		key := fmt.Sprint("key", id)
+		if id%3 == 0 {
+			key = "wrong"
+		}
+		creds = append(creds, Credential{CustomerID: id, APIKey: key})
+	}
+	creds = append(creds, Credential{CustomerID: 0, APIKey: "key0"})
+
+	tokens, errs := client.AuthMany(context.Background(), creds)
+	if len(tokens) != len(creds) || len(errs) != len(creds) {
+		t.Fatalf("got %d tokens and %d errors for %d credentials", len(tokens), len(errs), len(creds))
+	}
+	for i, cred := range creds {
+		switch {
+		case cred.CustomerID == 0:
+			if !errors.Is(errs[i], ErrInvalidCredentials) || tokens[i] != nil {
+				t.Errorf("%d: %v, %v, want ErrInvalidCredentials", i, tokens[i], errs[i])
+			}
+		case cred.APIKey == "wrong":
+			if errs[i] == nil || tokens[i] != nil {
+				t.Errorf("%d: %v, %v, want an error for the wrong key", i, tokens[i], errs[i])
+			}
+		case errs[i] != nil:
+			t.Errorf("%d: %v", i, errs[i])
+		case tokens[i].CustomerID != cred.CustomerID || tokens[i].Token != fmt.Sprint("token", cred.CustomerID):
+			t.Errorf("%d: token %+v for customer %d", i, tokens[i], cred.CustomerID)
+		}
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > authManyConcurrency {
+		t.Errorf("%d requests in flight, want at most %d", max, authManyConcurrency)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Leave out the invalid credentials, which fail before the context is looked at.
+	valid := creds[:len(creds)-1]
+	tokens, errs = client.AuthMany(ctx, valid)
+	for i := range valid {
+		if tokens[i] != nil || !errors.Is(errs[i], context.Canceled) {
+			t.Errorf("canceled %d: %v, %v, want context.Canceled", i, tokens[i], errs[i])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// DefaultMaxLikeIDs is the default maximum number of Like IDs a Client sends in a single request. See
+// WithMaxLikeIDs.
+const DefaultMaxLikeIDs = 10000
+
+// ErrTooManyLikeIDs is returned by PredictLikeIDs if more Like IDs are passed than allowed by
+// WithMaxLikeIDs and batching is disabled.
+var ErrTooManyLikeIDs = errors.New("too many like ids")
+
+// WithMaxLikeIDs sets the maximum number of Like IDs sent in a single request. Calls with more Like
+// IDs fail with ErrTooManyLikeIDs before anything is sent, unless WithLikeIDBatching is used. A value
+// of zero removes the limit and leaves the decision to the API. The default is DefaultMaxLikeIDs.
+func WithMaxLikeIDs(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("maximum number of like ids must not be negative")
+		}
+		c.maxLikeIDs = n
+		return nil
+	}
+}
+
+// WithLikeIDBatching makes PredictLikeIDs split calls with more Like IDs than allowed by
+// WithMaxLikeIDs into several requests. The predictions of the requests are merged with
+// MergePredictions, weighted by the number of Like IDs the API used for each of them.
+//
+// If the deadline of the context runs out before all requests are done, PredictLikeIDs returns the
+// merged predictions of the finished requests with Predictions.Partial set, together with an error
+// wrapping context.DeadlineExceeded.
+func WithLikeIDBatching(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.batchLikeIDs = enabled
+		return nil
+	}
+}
+
+func (c *Client) checkLikeIDCount(count int) error {
+	if c.maxLikeIDs > 0 && count > c.maxLikeIDs {
+		return fmt.Errorf("%w: %d (maximum is %d)", ErrTooManyLikeIDs, count, c.maxLikeIDs)
+	}
+	return nil
+}
+
+// predictBatched sends ids in chunks of at most c.maxLikeIDs and merges the results.
+//
+// If the deadline of ctx is too close to finish another chunk, judged by the average duration of the
+// chunks so far on the clock of the Client (see WithClock), no further chunks are started. The merged results of the finished chunks are returned
+// with Partial set, together with an error wrapping context.DeadlineExceeded.
+func (c *Client) predictBatched(ctx context.Context, ids []string, options url.Values, auth *Token) (predictions Predictions, err error) {
+	total := (len(ids) + c.maxLikeIDs - 1) / c.maxLikeIDs
+	var batches []WeightedPrediction
+	var elapsed time.Duration
+	for start := 0; start < len(ids); start += c.maxLikeIDs {
+		end := start + c.maxLikeIDs
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && len(batches) > 0 {
+			average := elapsed / time.Duration(len(batches))
+			if deadline.Sub(c.now()) < average {
+				err = fmt.Errorf("stopped after %d of %d chunks of like ids: %w", len(batches), total, context.DeadlineExceeded)
+				return mergeBatches(batches, ids[:start], true), err
+			}
+		}
+
+		started := c.now()
+		batch, err := c.PredictLikeIDs(ctx, ids[start:end], options, auth)
+		if err != nil {
+			err = fmt.Errorf("could not predict like ids %d to %d: %w", start, end, err)
+			if errors.Is(err, context.DeadlineExceeded) && len(batches) > 0 {
+				return mergeBatches(batches, ids[:start], true), err
+			}
+			return predictions, err
+		}
+		elapsed += c.now().Sub(started)
+		batches = append(batches, WeightedPrediction{Predictions: batch, Weight: float64(batch.InputUsed)})
+	}
+
+	return mergeBatches(batches, ids, false), nil
+}
+
+// mergeBatches merges the results of the chunks of the Like IDs ids.
+func mergeBatches(batches []WeightedPrediction, ids []string, partial bool) (predictions Predictions) {
+	predictions = MergePredictions(batches...)
+	predictions.Requested = batches[0].Requested
+	predictions.InputRef = InputRef{LikeIDs: append([]string(nil), ids...)}
+	predictions.Partial = predictions.Partial || partial
+	return predictions
+}
@@ -0,0 +1,176 @@
+package applymagicsauce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPredictBatched(t *testing.T) {
+	ids := []string{"1", "2", "3", "4", "5", "6", "7"}
+	tests := []struct {
+		name        string
+		options     []ClientOption
+		wantChunks  [][]string
+		wantDropped bool
+		wantErr     error
+	}{
+		{"no batching", []ClientOption{WithMaxLikeIDs(3)}, nil, false, ErrTooManyLikeIDs},
+		{"chunks", []ClientOption{WithMaxLikeIDs(3), WithLikeIDBatching(true)}, [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7"}}, false, nil},
+		{"contributors dropped for a chunk", []ClientOption{WithMaxLikeIDs(3), WithLikeIDBatching(true), WithMinContributorIDs(2)}, [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7"}}, true, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var chunks [][]string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				var chunk []string
+				json.NewDecoder(r.Body).Decode(&chunk)
+				mu.Lock()
+				chunks = append(chunks, chunk)
+				mu.Unlock()
+				w.Write([]byte(`{"input_used":1,"predictions":[{"trait":"BIG5_Openness","value":0.5}]}`))
+			}, test.options...)
+
+			options := PredictLikeIDsOptions([]string{TraitOpenness}, false, true)
+			predictions, err := client.PredictLikeIDs(context.Background(), ids, options, StubToken())
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(chunks, test.wantChunks) {
+				t.Errorf("chunks = %v, want %v", chunks, test.wantChunks)
+			}
+			if predictions.InputUsed != len(test.wantChunks) || predictions.Submitted != len(ids) {
+				t.Errorf("InputUsed = %d, Submitted = %d, want %d, %d", predictions.InputUsed, predictions.Submitted, len(test.wantChunks), len(ids))
+			}
+			if !reflect.DeepEqual(predictions.InputRef.LikeIDs, ids) {
+				t.Errorf("InputRef.LikeIDs = %v, want all ids", predictions.InputRef.LikeIDs)
+			}
+			if predictions.ContributorsDropped != test.wantDropped {
+				t.Errorf("ContributorsDropped = %v, want %v", predictions.ContributorsDropped, test.wantDropped)
+			}
+			if predictions.Partial {
+				t.Error("Partial is set")
+			}
+		})
+	}
+}
+
+func TestMaxLikeIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		count   int
+		wantErr bool
+	}{
+		{"default limit", nil, DefaultMaxLikeIDs, false},
+		{"above the default limit", nil, DefaultMaxLikeIDs + 1, true},
+		{"at the limit", []ClientOption{WithMaxLikeIDs(5)}, 5, false},
+		{"above the limit", []ClientOption{WithMaxLikeIDs(5)}, 6, true},
+		{"no limit", []ClientOption{WithMaxLikeIDs(0)}, DefaultMaxLikeIDs + 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte(`{"input_used":1}`))
+			}, test.options...)
+
+			ids := make([]string, test.count)
+			for i := range ids {
+				ids[i] = strconv.Itoa(i + 1)
+			}
+			_, err := client.PredictLikeIDs(context.Background(), ids, nil, StubToken())
+			if !test.wantErr {
+				if err != nil || requests != 1 {
+					t.Errorf("err = %v after %d requests, want a single request", err, requests)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTooManyLikeIDs) || !strings.Contains(err.Error(), strconv.Itoa(test.count)) {
+				t.Errorf("err = %v, want ErrTooManyLikeIDs with the count", err)
+			}
+			if requests != 0 {
+				t.Errorf("%d requests sent, want none", requests)
+			}
+		})
+	}
+}
+
+// clockDeadline is a context with a deadline on a fake clock, which never expires in real time.
+type clockDeadline struct {
+	context.Context
+	deadline time.Time
+}
+
+func (ctx clockDeadline) Deadline() (time.Time, bool) {
+	return ctx.deadline, true
+}
+
+func TestPredictBatchedDeadline(t *testing.T) {
+	// Every chunk takes 100ms on the clock of the Client.
+	clock := newFakeClock()
+	var chunks [][]string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var ids []string
+		json.NewDecoder(r.Body).Decode(&ids)
+		chunks = append(chunks, ids)
+		clock.advance(100 * time.Millisecond)
+		w.Write([]byte(`{"input_used":2}`))
+	}, append(clock.options(), WithMaxLikeIDs(2), WithLikeIDBatching(true))...)
+
+	tests := []struct {
+		name       string
+		remaining  time.Duration
+		wantChunks int
+	}{
+		// After two chunks, 50ms are left for the third one.
+		{"deadline", 250 * time.Millisecond, 2},
+		// The first chunk is always sent, there is no duration to judge by yet.
+		{"short deadline", time.Millisecond, 1},
+		{"enough time", time.Second, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks = nil
+			ctx := clockDeadline{Context: context.Background(), deadline: clock.Now().Add(test.remaining)}
+			predictions, err := client.PredictLikeIDs(ctx, []string{"1", "2", "3", "4", "5", "6"}, nil, StubToken())
+			if len(chunks) != test.wantChunks {
+				t.Fatalf("sent chunks %v, want %d", chunks, test.wantChunks)
+			}
+
+			if test.wantChunks == 3 {
+				if err != nil || predictions.Partial {
+					t.Errorf("err = %v, Partial = %v, want the complete result", err, predictions.Partial)
+				}
+				return
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+			}
+			if want := fmt.Sprintf("stopped after %d of 3 chunks", test.wantChunks); !strings.Contains(err.Error(), want) {
+				t.Errorf("err = %v, want it to say %q", err, want)
+			}
+			want := []string{"1", "2", "3", "4"}[:2*test.wantChunks]
+			if !predictions.Partial || predictions.InputUsed != 2*test.wantChunks || !reflect.DeepEqual(predictions.InputRef.LikeIDs, want) {
+				t.Errorf("Partial = %v, InputUsed = %d, LikeIDs = %v, want the merged results of the chunks sent",
+					predictions.Partial, predictions.InputUsed, predictions.InputRef.LikeIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package applymagicsauce
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Cache stores the results of prediction calls. See WithCache.
+//
+// Implementations must be safe for concurrent use by multiple goroutines. Callers may modify the
+// Predictions of the entries they pass to Set and get from Get, so implementations that keep entries
+// in memory should store and return copies (see Predictions.Clone).
+type Cache interface {
+	// Get returns the entry stored for key and whether there was one.
+	Get(key string) (entry CacheEntry, ok bool)
+	// Set stores entry for key, replacing any existing entry.
+	Set(key string, entry CacheEntry)
+}
+
+// CacheEntry is a single result stored in a Cache.
+type CacheEntry struct {
+	Predictions Predictions
+	// ETag is the entity tag the API sent along with the Predictions, if any. It is used for
+	// conditional requests (see WithConditionalRequests).
+	ETag string
+	// StoredAt is the time the entry was stored or last revalidated (see WithCacheTTL).
+	StoredAt time.Time
+}
+
+// CacheKey returns the key under which the result of a call to endpoint with the given options and
+// payload is stored. Calls with the same endpoint, options and payload share one key, independent of
+// the authentication token used.
+func CacheKey(endpoint string, options url.Values, payload []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(endpoint))
+	hash.Write([]byte{0})
+	hash.Write([]byte(options.Encode()))
+	hash.Write([]byte{0})
+	hash.Write(payload)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// MemoryCache is a simple Cache that keeps all entries in memory. It never evicts any entries.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]CacheEntry
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]CacheEntry),
+	}
+}
+
+// Get implements Cache. It returns a copy of the stored Predictions.
+func (m *MemoryCache) Get(key string) (entry CacheEntry, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok = m.entries[key]
+	entry.Predictions = entry.Predictions.Clone()
+	return entry, ok
+}
+
+// Set implements Cache. It stores a copy of the Predictions.
+func (m *MemoryCache) Set(key string, entry CacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry.Predictions = entry.Predictions.Clone()
+	m.entries[key] = entry
+}
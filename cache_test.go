@@ -0,0 +1,192 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheCopies(t *testing.T) {
+	cache := NewMemoryCache()
+	predictions := Predictions{
+		Predictions:  []PredictionEntry{{Trait: TraitOpenness, Value: 0.5}},
+		Contributors: []ContributorEntry{{Trait: TraitOpenness, Positive: []string{"1"}}},
+	}
+	cache.Set("key", CacheEntry{Predictions: predictions})
+	predictions.Predictions[0].Value = 1
+	predictions.Contributors[0].Positive[0] = "2"
+
+	entry, _ := cache.Get("key")
+	entry.Predictions.Predictions[0].Value = 2
+
+	entry, ok := cache.Get("key")
+	if !ok {
+		t.Fatal("entry not found")
+	}
+	if got := entry.Predictions.Predictions[0].Value; got != 0.5 {
+		t.Errorf("stored value = %v, want 0.5", got)
+	}
+	if got := entry.Predictions.Contributors[0].Positive[0]; got != "1" {
+		t.Errorf("stored contributor = %q, want 1", got)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []ClientOption
+		status      int
+		wantSource  ResultSource
+		wantRequest bool
+		wantETag    bool
+		wantErr     bool
+	}{
+		{"fresh entry", nil, http.StatusOK, SourceCache, false, false, false},
+		{"not modified", []ClientOption{WithConditionalRequests(true)}, http.StatusNotModified, SourceCache, true, true, false},
+		{"modified", []ClientOption{WithConditionalRequests(true)}, http.StatusOK, SourceLive, true, true, false},
+		{"stale on server error", []ClientOption{WithConditionalRequests(true), WithStaleOnError(true)}, http.StatusServiceUnavailable, SourceStale, true, true, false},
+		{"server error", []ClientOption{WithConditionalRequests(true)}, http.StatusServiceUnavailable, SourceLive, true, true, true},
+		{"expired entry", []ClientOption{WithCacheTTL(time.Nanosecond)}, http.StatusOK, SourceLive, true, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			status := http.StatusOK
+			var gotETag string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				gotETag = r.Header.Get("If-None-Match")
+				w.Header().Set("ETag", `"v1"`)
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(`{"input_used":3}`))
+				}
+			}, append([]ClientOption{WithCache(NewMemoryCache())}, test.options...)...)
+
+			options := MinimalBigFiveOptions(SourceOther)
+			if _, err := client.PredictText(context.Background(), "text", options, StubToken()); err != nil {
+				t.Fatalf("first call: %v", err)
+			}
+
+			requests, status = 0, test.status
+			predictions, err := client.PredictText(context.Background(), "text", options, StubToken())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if (requests > 0) != test.wantRequest {
+				t.Errorf("%d requests sent, want request: %v", requests, test.wantRequest)
+			}
+			if test.wantETag && gotETag != `"v1"` {
+				t.Errorf("If-None-Match = %q, want the ETag of the cached entry", gotETag)
+			}
+			if !test.wantETag && gotETag != "" {
+				t.Errorf("If-None-Match = %q without conditional requests", gotETag)
+			}
+			if err != nil {
+				return
+			}
+			if predictions.Source != test.wantSource {
+				t.Errorf("Source = %v, want %v", predictions.Source, test.wantSource)
+			}
+			if predictions.InputUsed != 3 {
+				t.Errorf("InputUsed = %d, want 3", predictions.InputUsed)
+			}
+		})
+	}
+}
+
+func TestNotModifiedWithoutEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		bypass  bool
+		wantErr bool
+	}{
+		{"intermediary answers", true, false},
+		{"always not modified", false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Header.Get("Cache-Control"))
+				if test.bypass && r.Header.Get("Cache-Control") == "no-cache" {
+					w.Write([]byte(`{"input_used":2}`))
+					return
+				}
+				w.WriteHeader(http.StatusNotModified)
+			}, WithCache(NewMemoryCache()), WithConditionalRequests(true))
+
+			predictions, err := client.PredictLikeIDs(context.Background(), []string{"1", "2"}, nil, StubToken())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if len(requests) != 2 || requests[0] != "" || requests[1] != "no-cache" {
+				t.Errorf("Cache-Control of the requests = %q, want a plain request and one with no-cache", requests)
+			}
+			if err == nil && (predictions.Source != SourceLive || predictions.InputUsed != 2) {
+				t.Errorf("predictions = %+v, want the live result", predictions)
+			}
+		})
+	}
+}
+
+func TestStaleOnError(t *testing.T) {
+	clock := newFakeClock()
+	var mu sync.Mutex
+	var inputUsed int
+	var fail string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch fail {
+		case "status":
+			w.WriteHeader(http.StatusBadGateway)
+		case "network":
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case "client error":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			fmt.Fprintf(w, `{"input_used":%d}`, inputUsed)
+		}
+	}, append(clock.options(), WithCache(NewMemoryCache()), WithCacheTTL(time.Minute), WithStaleOnError(true))...)
+
+	steps := []struct {
+		advance    time.Duration
+		fail       string
+		inputUsed  int
+		wantSource ResultSource
+		wantInput  int
+		wantErr    bool
+	}{
+		{0, "", 1, SourceLive, 1, false},
+		{0, "status", 0, SourceCache, 1, false},
+		{2 * time.Minute, "", 2, SourceLive, 2, false},
+		{2 * time.Minute, "status", 0, SourceStale, 2, false},
+		{0, "network", 0, SourceStale, 2, false},
+		{0, "client error", 0, 0, 0, true},
+	}
+	for i, step := range steps {
+		clock.advance(step.advance)
+		mu.Lock()
+		fail, inputUsed = step.fail, step.inputUsed
+		mu.Unlock()
+
+		predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+		if (err != nil) != step.wantErr {
+			t.Fatalf("step %d: err = %v, want error: %v", i, err, step.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if predictions.Source != step.wantSource || predictions.InputUsed != step.wantInput || predictions.Stale != (step.wantSource == SourceStale) {
+			t.Errorf("step %d: got %v with input %d (stale %t), want %v with input %d",
+				i, predictions.Source, predictions.InputUsed, predictions.Stale, step.wantSource, step.wantInput)
+		}
+	}
+}
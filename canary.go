@@ -0,0 +1,109 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxCanaryCalls is the maximum number of canary requests (see WithCanary) a Client runs at the same
+// time. Sampled calls that would exceed it are not sent to the canary.
+const maxCanaryCalls = 4
+
+type canary struct {
+	baseURL    string
+	sampleRate float64
+	compare    func(primary, canary Predictions)
+	slots      chan struct{}
+}
+
+// WithCanary sends a sampled fraction of the predictions to a second base URL as well, e.g. to try a
+// new region of the API before switching to it. For a share of sampleRate (0 to 1) of the successful
+// predictions, the same request is sent to altBaseURL in the background and compare is called with
+// both results. The caller always gets the primary result and does not wait for the canary.
+//
+// At most a few canary requests run at the same time; sampled calls beyond that are skipped. Canary
+// requests are sent with a copy of the token and never renew it. Their failures are logged (see
+// WithLogger), compare is only called for successful ones. compare gets its own copy of the
+// primary result, so the caller may modify the result it got in the meantime.
+//
+// Canary requests are real requests to the API: they are counted in Stats like all others and use up
+// the usage limits of the token, so a sampleRate of 0.1 costs about a tenth more calls. They are not
+// throttled by WithClientSideRateLimit, which only accounts for the calls of the caller.
+func WithCanary(altBaseURL string, sampleRate float64, compare func(primary, canary Predictions)) ClientOption {
+	return func(c *Client) error {
+		if _, err := url.Parse(altBaseURL); err != nil {
+			return fmt.Errorf("invalid canary base url: %v", err)
+		}
+		if sampleRate < 0 || sampleRate > 1 {
+			return fmt.Errorf("canary sample rate must be between 0 and 1")
+		}
+		if compare == nil {
+			return fmt.Errorf("canary compare function must not be nil")
+		}
+		c.canary = &canary{
+			baseURL:    strings.TrimSuffix(altBaseURL, "/"),
+			sampleRate: sampleRate,
+			compare:    compare,
+			slots:      make(chan struct{}, maxCanaryCalls),
+		}
+		return nil
+	}
+}
+
+type baseURLKey struct{}
+
+// requestBaseURL returns the base URL the requests of ctx are sent to.
+func (c *Client) requestBaseURL(ctx context.Context) string {
+	if baseURL, ok := ctx.Value(baseURLKey{}).(string); ok {
+		return baseURL
+	}
+	return c.baseURL
+}
+
+// startCanary sends a prediction request to the canary base URL in the background if the call is
+// sampled and compares the result with primary. See WithCanary.
+func (c *Client) startCanary(ctx context.Context, endpoint string, options url.Values, payload []byte, auth *Token, primary Predictions) {
+	if c.canary == nil || primary.Source != SourceLive || rand.Float64() >= c.canary.sampleRate {
+		return
+	}
+	select {
+	case c.canary.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	// The call returns before the canary is done, so the canary must neither use the context nor the
+	// token of the caller.
+	canaryCtx := WithNoRenew(context.WithValue(context.Background(), baseURLKey{}, c.canary.baseURL))
+	if id, ok := RequestIDFromContext(ctx); ok {
+		canaryCtx = ContextWithRequestID(canaryCtx, id)
+	}
+	token := copyToken(auth)
+	// The caller gets primary as well and may modify its lists while compare runs.
+	primary = primary.Clone()
+
+	go func() {
+		defer func() { <-c.canary.slots }()
+
+		predictions, err := c.fetchCanary(canaryCtx, endpoint, options, payload, token)
+		if err != nil {
+			c.logf(canaryCtx, "canary %s failed: %v", c.canary.baseURL, err)
+			return
+		}
+		c.canary.compare(primary, predictions)
+	}()
+}
+
+func (c *Client) fetchCanary(ctx context.Context, endpoint string, options url.Values, payload []byte, auth *Token) (predictions Predictions, err error) {
+	response, err := c.do(withStreaming(ctx), http.MethodPost, endpoint+"?"+options.Encode(), payload, nil, auth)
+	if err != nil || response.statusCode == http.StatusNoContent {
+		return predictions, err
+	}
+	err = c.decode(endpoint, response, &predictions)
+	predictions.Requested = requestedTraits(options)
+	return predictions, err
+}
@@ -0,0 +1,110 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCanaryStats(t *testing.T) {
+	canaryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":2}`))
+	}))
+	defer canaryServer.Close()
+
+	compared := make(chan Predictions, 1)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithCanary(canaryServer.URL, 1, func(primary, canary Predictions) { compared <- canary }))
+
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if predictions.InputUsed != 1 {
+		t.Errorf("InputUsed = %d, want the primary result 1", predictions.InputUsed)
+	}
+	if canary := <-compared; canary.InputUsed != 2 {
+		t.Errorf("canary InputUsed = %d, want 2", canary.InputUsed)
+	}
+
+	// The canary request is recorded before compare is called.
+	stats := client.Stats()
+	if stats.Requests != 2 || stats.EndpointRequests[EndpointLikeIDs] != 2 {
+		t.Errorf("Stats = %+v, want the canary request counted", stats)
+	}
+}
+
+func TestCanarySampleRate(t *testing.T) {
+	const calls = 400
+	tests := []struct {
+		rate     float64
+		min, max int
+	}{
+		{0, 0, 0},
+		{0.25, 60, 140},
+		{1, calls, calls},
+	}
+
+	canaryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":2}`))
+	}))
+	defer canaryServer.Close()
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint("rate ", test.rate), func(t *testing.T) {
+			var compared int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"input_used":1}`))
+			}, WithCanary(canaryServer.URL, test.rate, func(primary, canary Predictions) {
+				if primary.InputUsed != 1 || canary.InputUsed != 2 {
+					t.Errorf("compared %d with %d, want the primary and the canary result", primary.InputUsed, canary.InputUsed)
+				}
+				atomic.AddInt32(&compared, 1)
+			}))
+
+			for i := 0; i < calls; i++ {
+				if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+					t.Fatal(err)
+				}
+				// Wait for the canary, so that no sampled call is skipped for lack of a free slot.
+				for len(client.canary.slots) > 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if n := int(atomic.LoadInt32(&compared)); n < test.min || n > test.max {
+				t.Errorf("%d of %d calls compared, want between %d and %d", n, calls, test.min, test.max)
+			}
+		})
+	}
+}
+
+func TestCanaryCopiesPrimary(t *testing.T) {
+	canaryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1,"predictions":[{"trait":"Age","value":31}]}`))
+	}))
+	defer canaryServer.Close()
+
+	modified := make(chan struct{})
+	compared := make(chan float64, 1)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1,"predictions":[{"trait":"Age","value":30}]}`))
+	}, WithCanary(canaryServer.URL, 1, func(primary, canary Predictions) {
+		<-modified
+		compared <- primary.Predictions[0].Value
+	}))
+
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	predictions.Predictions[0].Value = 99
+	close(modified)
+	if value := <-compared; value != 30 {
+		t.Errorf("compare got a primary value of %v, want the value of the response", value)
+	}
+}
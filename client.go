@@ -0,0 +1,956 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client provides access to the API with a custom configuration. The package-level functions (Auth,
+// PredictLikeIDs and PredictText) use a Client with the default configuration, so you only need to
+// create one if you want to change any of the defaults. The only difference is that the package-level
+// functions skip checks that would reject calls they used to accept (see WithAllowUnknownTraits and
+// ValidateOptions).
+//
+// A Client is safe for concurrent use by multiple goroutines.
+type Client struct {
+	baseURL          string
+	customBaseURL    bool
+	httpClient       *http.Client
+	customHTTPClient bool
+	proxyURL         *url.URL
+	tlsConfig        *tls.Config
+	defaultDeadline  time.Duration
+	clock            func() time.Time
+	timer            func(d time.Duration) (expired <-chan time.Time, stop func() bool)
+
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+
+	responseHeaderTimeout time.Duration
+
+	warmupMu sync.Mutex
+	warmAt   time.Time
+
+	cache        Cache
+	cacheTTL     time.Duration
+	conditional  bool
+	staleOnError bool
+
+	allowedLanguages map[string]bool
+	languageDetector LanguageDetector
+	preprocessText   func(string) string
+
+	textWeight  float64
+	likesWeight float64
+
+	allowUnknownTraits   bool
+	dropTextContributors bool
+	// skipOptionChecks disables ValidateOptions for the predictions of the package-level functions.
+	skipOptionChecks bool
+
+	defaultTextOptions url.Values
+	defaultLikeOptions url.Values
+
+	maxLikeIDs        int
+	minContributorIDs int
+	batchLikeIDs      bool
+	maxRequestBytes   int
+
+	rateLimiter *rateLimiter
+
+	rateLimitHeaders rateLimitHeaders
+	rateLimitMu      sync.Mutex
+	lastRateLimit    RateLimit
+	hasRateLimit     bool
+
+	stats stats
+
+	logger    Logger
+	observer  func(RequestEvent)
+	httpTrace bool
+
+	maxAttempts    int
+	retryDelay     time.Duration
+	retryBudget    *retryBudget
+	idempotencyKey func() string
+	retryDecider   func(resp *http.Response, err error) bool
+
+	replayDir  string
+	replayMode ReplayMode
+
+	codec           JSONCodec
+	strictResponses bool
+
+	dryRun bool
+
+	contentTypes   map[string]string
+	accept         string
+	acceptLanguage string
+	modifyRequest  func(*http.Request) error
+
+	endpoints  Endpoints
+	apiVersion string
+	canary     *canary
+
+	renewal          renewalMode
+	authFailureCodes map[int]bool
+	tokenProvider    func(ctx context.Context) (*Token, error)
+	authenticator    *Authenticator
+
+	credentialsMu sync.RWMutex
+	credentials   map[int]string
+
+	// renewMu serializes token renewals. renewedFrom is the last token that was renewed and renewedTo
+	// the token it was renewed to, so that concurrent calls rejected with the same token renew it once.
+	renewMu     sync.Mutex
+	renewedFrom string
+	renewedTo   *Token
+}
+
+// ClientOption configures a Client. Use the With* functions to obtain one.
+type ClientOption func(*Client) error
+
+// NewClient returns a Client with the default configuration, modified by the passed options.
+func NewClient(options ...ClientOption) (*Client, error) {
+	client := defaultClient()
+	for _, option := range options {
+		if err := option(client); err != nil {
+			return nil, err
+		}
+	}
+	if err := client.setupTransport(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func defaultClient() *Client {
+	return &Client{
+		baseURL:         apiURL,
+		httpClient:      &http.Client{},
+		defaultDeadline: DefaultDeadline,
+		clock:           time.Now,
+		timer:           newTimer,
+		textWeight:      0.5,
+		likesWeight:     0.5,
+		maxAttempts:     1,
+		maxLikeIDs:      DefaultMaxLikeIDs,
+		codec:           stdCodec{},
+		accept:          "application/json",
+		endpoints:       DefaultEndpoints,
+		rateLimitHeaders: rateLimitHeaders{
+			limit:     DefaultRateLimitLimitHeader,
+			remaining: DefaultRateLimitRemainingHeader,
+			reset:     DefaultRateLimitResetHeader,
+		},
+	}
+}
+
+// packageClient returns the Client used by the package-level functions. They predate the Client, so
+// checks and limits that would reject previously accepted calls are disabled. Their only timeout is
+// DefaultTimeout, applied as the default deadline of every request (see WithDefaultDeadline).
+func packageClient() *Client {
+	client := defaultClient()
+	client.defaultDeadline = DefaultTimeout
+	client.allowUnknownTraits = true
+	client.dropTextContributors = true
+	client.skipOptionChecks = true
+	client.maxLikeIDs = 0
+	return client
+}
+
+// WithBaseURL sets the URL the Client sends its requests to. This is mostly useful for testing
+// against a mock server. It takes precedence over WithEnvironment, independent of the order of the
+// options.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		if _, err := url.Parse(baseURL); err != nil {
+			return fmt.Errorf("invalid base url: %v", err)
+		}
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+		c.customBaseURL = true
+		return nil
+	}
+}
+
+// Environment is an environment of the API. See WithEnvironment.
+type Environment int
+
+// Available environments.
+const (
+	EnvProduction Environment = iota
+	EnvStaging
+)
+
+// URL returns the base URL of the environment.
+func (e Environment) URL() string {
+	switch e {
+	case EnvStaging:
+		return StagingURL
+	default:
+		return ProductionURL
+	}
+}
+
+// WithEnvironment sets the base URL to the one of the given environment. The default is
+// EnvProduction. A base URL set with WithBaseURL takes precedence.
+func WithEnvironment(environment Environment) ClientOption {
+	return func(c *Client) error {
+		if environment != EnvProduction && environment != EnvStaging {
+			return fmt.Errorf("unknown environment: %d", environment)
+		}
+		if !c.customBaseURL {
+			c.baseURL = environment.URL()
+		}
+		return nil
+	}
+}
+
+// WithHTTPClient sets the http.Client used for all requests. The default is a http.Client without an
+// overall timeout, whose transport limits the time to connect and to receive the response headers
+// (see WithResponseHeaderTimeout). Reading the body of a response is only limited by the context, so
+// that large responses that arrive slowly but steadily are not aborted (see WithDefaultDeadline).
+//
+// The options that configure the transport (e.g. WithProxy) can not be combined with a custom
+// http.Client. Configure its transport directly instead.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return fmt.Errorf("http client must not be nil")
+		}
+		c.httpClient = httpClient
+		c.customHTTPClient = true
+		return nil
+	}
+}
+
+// DefaultDeadline is the default for WithDefaultDeadline.
+const DefaultDeadline = 5 * time.Minute
+
+// WithDefaultDeadline sets the deadline applied to a call if neither its context has a deadline nor
+// the http.Client has a timeout, e.g. with context.Background(). It prevents calls from hanging forever
+// on a server that stalls while sending a response. The deadline covers all attempts of the call,
+// including retries and reading the responses.
+//
+// A deadline of the context always takes precedence, followed by the timeout of the http.Client. A
+// value of zero disables the default deadline. The default is DefaultDeadline.
+func WithDefaultDeadline(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("default deadline must not be negative")
+		}
+		c.defaultDeadline = d
+		return nil
+	}
+}
+
+// WithClock sets the function the Client uses to get the current time, e.g. to control the expiry of
+// cached results and tokens in tests. Batched Like IDs (see WithLikeIDBatching) compare the deadline of
+// the context with this clock. Durations such as the latency in Stats are always measured with the
+// real clock. The default is time.Now.
+func WithClock(now func() time.Time) ClientOption {
+	return func(c *Client) error {
+		if now == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		c.clock = now
+		return nil
+	}
+}
+
+func (c *Client) now() time.Time {
+	return c.clock()
+}
+
+// WithTimer sets the function the Client starts timers with, to go along with a clock set with
+// WithClock. It is used for the delays between retries, the waits of WithClientSideRateLimit and the
+// background refresh of an Authenticator (see Authenticator.StartAutoRefresh). The returned channel must receive once d has
+// passed on the clock, until stop is called. The default uses time.NewTimer.
+func WithTimer(timer func(d time.Duration) (expired <-chan time.Time, stop func() bool)) ClientOption {
+	return func(c *Client) error {
+		if timer == nil {
+			return fmt.Errorf("timer must not be nil")
+		}
+		c.timer = timer
+		return nil
+	}
+}
+
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// WithCache sets a Cache for the results of PredictLikeIDs and PredictText. Predictions for an input
+// that has been seen before are answered from the cache without calling the API.
+func WithCache(cache Cache) ClientOption {
+	return func(c *Client) error {
+		c.cache = cache
+		return nil
+	}
+}
+
+// WithCacheTTL limits how long a cached result is used without asking the API again. Expired entries
+// are requested again like new ones; they stay in the cache for WithStaleOnError. The default of zero
+// means that cached results never expire.
+func WithCacheTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) error {
+		if ttl < 0 {
+			return fmt.Errorf("cache ttl must not be negative")
+		}
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithStaleOnError makes PredictLikeIDs and PredictText return the cached result for an input if the
+// API is not available (network errors or a 5xx status code). Such results have Predictions.Stale set
+// to true, so you can decide whether you still want to trust them.
+//
+// This option has no effect without WithCache.
+func WithStaleOnError(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.staleOnError = enabled
+		return nil
+	}
+}
+
+// WithConditionalRequests makes the Client revalidate cached predictions with the API instead of
+// answering from the cache right away. The ETag of the last response is sent in the If-None-Match
+// header and a "304 Not Modified" response reuses the cached Predictions.
+//
+// This option has no effect without WithCache.
+func WithConditionalRequests(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.conditional = enabled
+		return nil
+	}
+}
+
+// WithTextPreprocessor sets a function that PredictText applies to every text before it is sent, e.g.
+// to strip signatures or quoted replies from emails. It runs exactly once per call, before the language
+// filter (see WithLanguageFilter) and before the request is built, so a repeated request never
+// processes the text twice.
+func WithTextPreprocessor(preprocess func(text string) string) ClientOption {
+	return func(c *Client) error {
+		c.preprocessText = preprocess
+		return nil
+	}
+}
+
+// ErrTokenExpired is returned by the predict functions if the API rejected the token and it was not
+// renewed (see WithAutoRenew). It is wrapped in an *APIError, so check for it with errors.Is.
+var ErrTokenExpired = errors.New("authentication token expired")
+
+// ErrInvalidCredentials is returned by Auth if the customer ID or the API key are obviously invalid,
+// without asking the API.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrAuthFailure is returned by Auth if the API did not issue a token, either because it rejected the
+// credentials or because its response contained no token. Check for it with errors.Is.
+var ErrAuthFailure = errors.New("authentication failure")
+
+// ErrContributorsNotSupported is returned by Client.PredictText and ValidateOptions if the options for
+// the text endpoint contain OptionsContributors. The text endpoint does not support them (yet).
+var ErrContributorsNotSupported = errors.New("contributors are not supported by the text endpoint")
+
+// WithDryRun makes the Client validate all calls as usual, but never send them to the API. Instead, Auth
+// returns an empty Token with DryRun set and the predict functions return empty Predictions with the
+// Source SourceDryRun. This allows checking the construction of requests without using up the usage
+// limit.
+func WithDryRun(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.dryRun = enabled
+		return nil
+	}
+}
+
+// Auth uses the passed customerID and apiKey (obtained during registration on https://applymagicsauce.com)
+// to get a valid authentication token.
+func (c *Client) Auth(ctx context.Context, customerID int, apiKey string) (authToken *Token, err error) {
+	if apiKey == "" {
+		apiKey = globalAPIKey()
+	}
+
+	switch {
+	case customerID <= 0:
+		return nil, fmt.Errorf("%w: customer id must be positive", ErrInvalidCredentials)
+	case apiKey == "":
+		return nil, fmt.Errorf("%w: api key is missing", ErrInvalidCredentials)
+	}
+
+	payload := struct {
+		CustomerID int    `json:"customer_id"`
+		APIKey     string `json:"api_key"`
+	}{
+		CustomerID: customerID,
+		APIKey:     apiKey,
+	}
+
+	payloadJSON, err := c.codec.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.dryRun {
+		return &Token{CustomerID: customerID, DryRun: true}, nil
+	}
+
+	response, err := c.do(ctx, http.MethodPost, EndpointAuth, payloadJSON, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	authToken = new(Token)
+	if err = c.decode(EndpointAuth, response, authToken); err != nil {
+		return nil, err
+	}
+	if authToken.Token == "" {
+		// Some failures are reported with a status of 200 and an error message in the body.
+		return nil, fmt.Errorf("%w: no token in response: %s", ErrAuthFailure, response.body)
+	}
+	authToken.apiKey = apiKey
+	return authToken, nil
+}
+
+// PredictLikeIDs queries the API with the provided Like IDs and returns the corresponding predictions.
+// See the package-level PredictLikeIDs for details.
+func (c *Client) PredictLikeIDs(ctx context.Context, ids []string, options url.Values, auth *Token) (predictions Predictions, err error) {
+	ctx = c.withRequestID(ctx)
+	options = withDefaults(options, c.defaultLikeOptions)
+	if err = c.checkLikeIDCount(len(ids)); err != nil {
+		if !c.batchLikeIDs {
+			return predictions, err
+		}
+		options, dropped := c.dropContributors(ctx, len(ids), options)
+		predictions, err = c.predictBatched(ctx, ids, options, auth)
+		predictions.ContributorsDropped = predictions.ContributorsDropped || dropped
+		return predictions, err
+	}
+
+	payloadJSON, err := c.encodeLikeIDs(ids)
+	if err != nil {
+		return predictions, err
+	}
+	return c.predictLikes(ctx, ids, payloadJSON, options, auth)
+}
+
+// encodeLikeIDs encodes ids as the payload of EndpointLikeIDs with the codec of the Client.
+func (c *Client) encodeLikeIDs(ids []string) ([]byte, error) {
+	return c.codec.Marshal(ids)
+}
+
+// predictLikes predicts ids, encoded as payload, and records them in the result. It is shared by
+// PredictLikeIDs and PredictPrepared, so that both treat the Like IDs alike.
+func (c *Client) predictLikes(ctx context.Context, ids []string, payload []byte, options url.Values, auth *Token) (predictions Predictions, err error) {
+	options, dropped := c.dropContributors(ctx, len(ids), options)
+	predictions, err = c.predict(ctx, EndpointLikeIDs, options, payload, auth)
+	predictions.Submitted = len(ids)
+	predictions.InputRef = InputRef{LikeIDs: append([]string(nil), ids...)}
+	predictions.ContributorsDropped = dropped
+	return predictions, err
+}
+
+// PredictText queries the API with the provided text and returns the corresponding predictions.
+// See the package-level PredictText for details. Unlike the package-level function, it checks the
+// options with ValidateOptions: it does not drop OptionsContributors silently but fails with
+// ErrContributorsNotSupported if they are set at all.
+func (c *Client) PredictText(ctx context.Context, text string, options url.Values, auth *Token) (predictions Predictions, err error) {
+	options = withDefaults(options, c.defaultTextOptions)
+	if c.preprocessText != nil {
+		text = c.preprocessText(text)
+	}
+
+	if err = c.checkLanguage(text); err != nil {
+		return predictions, err
+	}
+
+	// The options are checked by predict, with ValidateOptions; only the package-level PredictText
+	// drops contributors silently.
+	if _, ok := options[OptionsContributors]; ok && c.dropTextContributors {
+		options = cloneValues(options)
+		options.Del(OptionsContributors)
+	}
+
+	predictions, err = c.predict(ctx, EndpointText, options, []byte(text), auth)
+	hash := sha256.Sum256([]byte(text))
+	predictions.InputRef = InputRef{TextHash: hex.EncodeToString(hash[:])}
+	return predictions, err
+}
+
+func (c *Client) predict(ctx context.Context, endpoint string, options url.Values, payload []byte, auth *Token) (predictions Predictions, err error) {
+	ctx = c.withRequestID(ctx)
+	if err = c.checkOptions(endpoint, options); err != nil {
+		return predictions, err
+	}
+	if err = c.checkPayloadSize(payload); err != nil {
+		return predictions, err
+	}
+
+	if c.dryRun {
+		predictions = Predictions{Source: SourceDryRun}
+	} else if auth, err = c.authenticated(ctx, auth); err == nil {
+		predictions, err = c.fetchPredictions(ctx, endpoint, options, payload, auth)
+	}
+	if traits := requestedTraits(options); len(traits) > 0 && err == nil {
+		predictions.Requested = traits
+	}
+	if err == nil {
+		c.startCanary(ctx, endpoint, options, payload, auth, predictions)
+	}
+	return predictions, err
+}
+
+func (c *Client) fetchPredictions(ctx context.Context, endpoint string, options url.Values, payload []byte, auth *Token) (predictions Predictions, err error) {
+	key := CacheKey(endpoint, options, payload)
+
+	var cached CacheEntry
+	var hit bool
+	if c.cache != nil {
+		cached, hit = c.cache.Get(key)
+		if hit && !c.conditional && c.isFresh(cached) {
+			return fromCache(cached.Predictions), nil
+		}
+	}
+
+	if c.rateLimiter != nil {
+		if err = c.rateLimiter.wait(ctx, endpoint, auth, c.now(), c.timer); err != nil {
+			return predictions, err
+		}
+	}
+
+	header := http.Header{}
+	revalidate := hit && c.conditional && cached.ETag != ""
+	if revalidate {
+		header.Set("If-None-Match", cached.ETag)
+	}
+
+	response, err := c.do(withStreaming(ctx), http.MethodPost, endpoint+"?"+options.Encode(), payload, header, auth)
+	if response == nil {
+		if hit && c.staleOnError && ctx.Err() == nil {
+			return stale(cached.Predictions), nil
+		}
+		return predictions, err
+	}
+
+	if response.statusCode == http.StatusNotModified {
+		if revalidate {
+			cached.StoredAt = c.now()
+			c.cache.Set(key, cached)
+			return fromCache(cached.Predictions), nil
+		}
+		// We did not ask for revalidation, so the response came from some intermediary. Repeat the
+		// request as a normal one and make sure it gets answered by the API.
+		header.Set("Cache-Control", "no-cache")
+		response, err = c.do(withStreaming(ctx), http.MethodPost, endpoint+"?"+options.Encode(), payload, header, auth)
+		if response == nil {
+			return predictions, err
+		}
+		if response.statusCode == http.StatusNotModified {
+			return predictions, fmt.Errorf("not modified, but no cached predictions available")
+		}
+	}
+
+	if response.statusCode >= http.StatusInternalServerError && hit && c.staleOnError {
+		return stale(cached.Predictions), nil
+	}
+
+	if c.isAuthFailure(response.statusCode) && c.shouldRenew(auth) && !noRenew(ctx) {
+		c.logf(ctx, "token rejected with status %d, renewing", response.statusCode)
+		err = c.renewToken(ctx, auth)
+		if err != nil {
+			return predictions, err
+		}
+		// The token is renewed at most once per call. If the API rejects the new token as well, the
+		// call fails with ErrTokenExpired instead of renewing again and again.
+		return c.fetchPredictions(WithNoRenew(ctx), endpoint, options, payload, auth)
+	}
+	if err != nil {
+		return predictions, err
+	}
+	if response.statusCode == http.StatusNoContent {
+		return predictions, nil
+	}
+
+	if err = c.decode(endpoint, response, &predictions); err != nil {
+		// A response that could not be decoded completely may have filled some of the fields.
+		return Predictions{}, err
+	}
+	if err = c.checkPredictions(predictions); err != nil {
+		return predictions, err
+	}
+
+	if c.cache != nil {
+		c.cache.Set(key, CacheEntry{
+			Predictions: predictions,
+			ETag:        response.header.Get("ETag"),
+			StoredAt:    c.now(),
+		})
+	}
+
+	return predictions, nil
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+	return clone
+}
+
+func (c *Client) isFresh(entry CacheEntry) bool {
+	return c.cacheTTL == 0 || c.now().Sub(entry.StoredAt) < c.cacheTTL
+}
+
+func fromCache(predictions Predictions) Predictions {
+	predictions.Source = SourceCache
+	return predictions
+}
+
+func stale(predictions Predictions) Predictions {
+	predictions.Source = SourceStale
+	predictions.Stale = true
+	return predictions
+}
+
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+
+	// streamed is set instead of body if the body of a successful prediction response was decoded
+	// while it was read (see streamable). decodeErr is the error of decoding it and prefix the
+	// beginning of the body, for the error message.
+	streamed  *Predictions
+	decodeErr error
+	prefix    []byte
+
+	// attempts is the number of requests doRequest sent to get the response.
+	attempts int
+}
+
+// doRequest sends a request to endpoint and retries it as configured with WithRetry.
+func (c *Client) doRequest(ctx context.Context, method string, endpoint string, payload []byte, header http.Header, auth *Token) (*response, error) {
+	if _, ok := ctx.Deadline(); !ok && c.httpClient.Timeout == 0 && c.defaultDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.defaultDeadline)
+		defer cancel()
+	}
+	ctx = c.withRequestID(ctx)
+
+	if c.idempotencyKey != nil {
+		header = header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Idempotency-Key", c.idempotencyKey())
+	}
+
+	if c.retryBudget != nil {
+		c.retryBudget.request(c.now())
+	}
+
+	lastStatus := 0
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		traceCtx, trace := c.withTrace(ctx)
+		response, err := c.send(traceCtx, method, endpoint, payload, header, auth)
+		c.observe(ctx, method, endpoint, attempt, response, err, time.Since(start), trace.traceInfo())
+		if response != nil {
+			response.attempts = attempt
+			lastStatus = response.statusCode
+		}
+		if attempt >= c.maxAttempts || !c.retryable(ctx, response, err) ||
+			c.retryBudget != nil && !c.retryBudget.retry(c.now()) {
+			if err != nil && attempt > 1 {
+				err = &APIError{Endpoint: endpointPath(endpoint), Attempts: attempt, LastStatus: lastStatus, err: err}
+			}
+			return response, err
+		}
+
+		c.stats.retries.Add(1)
+		delay := c.backoff(attempt)
+		c.logf(ctx, "retrying %s %s in %s", method, endpointPath(endpoint), delay)
+		expired, stop := c.timer(delay)
+		select {
+		case <-expired:
+		case <-ctx.Done():
+			stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, endpoint string, payload []byte, header http.Header, auth *Token) (*response, error) {
+	if info := callInfoFrom(ctx); info != nil {
+		info.attempts.Add(1)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.requestBaseURL(ctx)+c.resolveEndpoint(endpoint), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range header {
+		request.Header.Set(key, header.Get(key))
+	}
+	request.Header.Set("Content-Type", c.contentType(endpoint))
+	request.Header.Set("Accept", c.accept)
+	if c.acceptLanguage != "" && endpointPath(endpoint) == EndpointText {
+		request.Header.Set("Accept-Language", c.acceptLanguage)
+	}
+	if auth != nil {
+		request.Header.Set("X-Auth-Token", auth.Token)
+	}
+
+	if c.modifyRequest != nil {
+		if err = c.modifyRequest(request); err != nil {
+			return nil, &requestModifierError{err: err}
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(request)
+	if err != nil {
+		c.stats.record(endpoint, 0, err, time.Since(start))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	c.recordRateLimit(resp.Header, c.now())
+
+	if c.streamable(ctx, resp) {
+		response, err := streamResponse(resp)
+		c.stats.record(endpoint, resp.StatusCode, err, time.Since(start))
+		if err != nil {
+			return nil, fmt.Errorf("could not read response of %s (status %d): %w", endpointPath(endpoint), resp.StatusCode, err)
+		}
+		return response, nil
+	}
+
+	body, err := readBody(resp.Body, resp.ContentLength)
+	c.stats.record(endpoint, resp.StatusCode, err, time.Since(start))
+	if err != nil {
+		// Never hand out a partial body, it must not be mistaken for a complete response.
+		return nil, fmt.Errorf("could not read response of %s (status %d): %w", endpointPath(endpoint), resp.StatusCode, err)
+	}
+
+	return &response{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       body,
+	}, nil
+}
+
+// maxPresizedBody is the largest Content-Length readBody allocates a buffer for up front, so that a
+// bogus header can not make it allocate huge amounts of memory.
+const maxPresizedBody = 64 << 20
+
+// readBody reads r completely. If the size of the body is known from the Content-Length header, the
+// buffer is allocated once instead of growing while reading.
+func readBody(r io.Reader, contentLength int64) ([]byte, error) {
+	if contentLength <= 0 || contentLength > maxPresizedBody {
+		return ioutil.ReadAll(r)
+	}
+	// The extra bytes.MinRead leave room for the final read that detects the end of the body.
+	buf := bytes.NewBuffer(make([]byte, 0, contentLength+bytes.MinRead))
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// WithAutoRenew sets whether the Client renews an expired token automatically and repeats the call.
+// The token is renewed with the API key it was obtained with, or APIKey for tokens that were not
+// returned by Auth.
+//
+// Without this option the Client behaves like the package-level functions: it renews tokens if APIKey
+// is set. Disable renewal to handle expired tokens yourself, even if APIKey is set. Calls with an
+// expired token then fail with ErrTokenExpired.
+func WithAutoRenew(enabled bool) ClientOption {
+	return func(c *Client) error {
+		if enabled {
+			c.renewal = renewAlways
+		} else {
+			c.renewal = renewNever
+		}
+		return nil
+	}
+}
+
+// WithAuthFailureCodes sets the status codes with which the API rejects a token. Only these trigger
+// the renewal of the token (see WithAutoRenew), result in ErrTokenExpired (ErrAuthFailure for Auth)
+// and make ValidateToken report the token as invalid. The default is 403 (Forbidden), which is what
+// the API uses. Pass all codes that should count, e.g. 401 and 403.
+func WithAuthFailureCodes(codes ...int) ClientOption {
+	return func(c *Client) error {
+		if len(codes) == 0 {
+			return fmt.Errorf("at least one auth failure code is required")
+		}
+		c.authFailureCodes = make(map[int]bool, len(codes))
+		for _, code := range codes {
+			c.authFailureCodes[code] = true
+		}
+		return nil
+	}
+}
+
+func (c *Client) isAuthFailure(status int) bool {
+	if c.authFailureCodes == nil {
+		return status == http.StatusForbidden
+	}
+	return c.authFailureCodes[status]
+}
+
+type noRenewKey struct{}
+
+// WithNoRenew returns a copy of ctx that disables the automatic renewal of tokens for calls made with
+// it, independent of WithAutoRenew. Such calls fail with ErrTokenExpired if the API rejects the token,
+// e.g. in a health check that should report an expired token instead of renewing it.
+func WithNoRenew(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRenewKey{}, true)
+}
+
+func noRenew(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRenewKey{}).(bool)
+	return disabled
+}
+
+type renewalMode int
+
+const (
+	// renewWithAPIKey renews tokens if APIKey is set. This is the behaviour of the package-level
+	// functions.
+	renewWithAPIKey renewalMode = iota
+	renewAlways
+	renewNever
+)
+
+// WithTokenProvider sets a function that the Client calls for a new token when the API rejects the
+// current one, instead of renewing it with Auth. This is useful if tokens are managed by some other
+// service. The new token replaces the content of the rejected one and the call is repeated.
+//
+// A token provider enables automatic renewal unless WithAutoRenew(false) is set.
+func WithTokenProvider(provider func(ctx context.Context) (*Token, error)) ClientOption {
+	return func(c *Client) error {
+		c.tokenProvider = provider
+		return nil
+	}
+}
+
+func (c *Client) shouldRenew(auth *Token) bool {
+	switch c.renewal {
+	case renewAlways:
+		return true
+	case renewNever:
+		return false
+	default:
+		return c.tokenProvider != nil || c.authenticator != nil || c.renewalAPIKey(auth.CustomerID) != "" || globalAPIKey() != ""
+	}
+}
+
+// SetCredentials sets the API key the Client renews the tokens of customerID with from now on, e.g.
+// after the key has been rotated, without creating a new Client. Tokens of other customers are still
+// renewed with their own keys. The key takes precedence over the key a token was obtained with and
+// over APIKey, but not over WithTokenProvider or WithAuthenticator. It is safe to call while other
+// goroutines make calls; renewals that have already started finish with the previous key.
+func (c *Client) SetCredentials(customerID int, apiKey string) {
+	c.credentialsMu.Lock()
+	defer c.credentialsMu.Unlock()
+	if c.credentials == nil {
+		c.credentials = make(map[int]string)
+	}
+	c.credentials[customerID] = apiKey
+}
+
+// renewalAPIKey returns the API key set with SetCredentials for customerID, if any.
+func (c *Client) renewalAPIKey(customerID int) string {
+	c.credentialsMu.RLock()
+	defer c.credentialsMu.RUnlock()
+	return c.credentials[customerID]
+}
+
+// renewToken replaces the content of auth with a new token. The renewal is bound to ctx, so cancelling
+// the call that triggered it also cancels the renewal. Unless a token provider or an Authenticator is
+// configured, the token is requested with c.Auth, so the renewal shares the transport, base URL and
+// all other options with the call that triggered it.
+func (c *Client) renewToken(ctx context.Context, auth *Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.renewMu.Lock()
+	defer c.renewMu.Unlock()
+	if c.renewedTo != nil && auth.Token == c.renewedFrom {
+		setToken(auth, c.renewedTo)
+		return nil
+	}
+
+	var token *Token
+	var err error
+	if c.tokenProvider != nil {
+		token, err = c.tokenProvider(ctx)
+		if err == nil && token == nil {
+			err = fmt.Errorf("token provider returned no token")
+		}
+	} else if c.authenticator != nil {
+		token, err = c.authenticator.renew(ctx, auth.Token)
+	} else {
+		apiKey := auth.apiKey
+		if key := c.renewalAPIKey(auth.CustomerID); key != "" {
+			apiKey = key
+		}
+		if apiKey == "" {
+			apiKey = globalAPIKey()
+		}
+		if apiKey == "" {
+			return fmt.Errorf("could not renew authentication token: %w: no api key available", ErrInvalidCredentials)
+		}
+		token, err = c.Auth(ctx, auth.CustomerID, apiKey)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("could not renew authentication token: %w", ctxErr)
+		}
+		return fmt.Errorf("could not renew authentication token: %w", err)
+	}
+
+	c.renewedFrom, c.renewedTo = auth.Token, copyToken(token)
+	setToken(auth, token)
+
+	return nil
+}
+
+// setToken replaces the fields of auth with those of token, so that the caller's *Token is renewed in
+// place.
+func setToken(auth, token *Token) {
+	auth.CustomerID = token.CustomerID
+	auth.Expires = token.Expires
+	auth.expiresAt = token.expiresAt
+	auth.apiKey = token.apiKey
+	auth.Permissions = token.Permissions
+	auth.Token = token.Token
+	auth.UsageLimits = token.UsageLimits
+}
+
+// copyToken returns a copy of auth, or nil if auth is nil. Calls that run concurrently each get their
+// own copy, as renewToken modifies the token in place.
+func copyToken(auth *Token) *Token {
+	if auth == nil {
+		return nil
+	}
+	copied := *auth
+	return &copied
+}
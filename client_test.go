@@ -0,0 +1,646 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Client that sends its requests to a test server running handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc, options ...ClientOption) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(append([]ClientOption{WithBaseURL(server.URL)}, options...)...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestSetCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials map[int]string
+		autoRenew   bool
+		wantAuth    string
+		wantErr     error
+	}{
+		{"same customer", map[int]string{1: "rotated"}, false, "1 rotated", nil},
+		{"other customer", map[int]string{2: "other"}, false, "", ErrTokenExpired},
+		{"other customer with auto renew", map[int]string{2: "other"}, true, "1 original", nil},
+		{"several customers", map[int]string{1: "rotated", 2: "other"}, false, "1 rotated", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotAuth string
+			var options []ClientOption
+			if test.autoRenew {
+				options = append(options, WithAutoRenew(true))
+			}
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == EndpointAuth {
+					var credentials struct {
+						CustomerID int    `json:"customer_id"`
+						APIKey     string `json:"api_key"`
+					}
+					json.NewDecoder(r.Body).Decode(&credentials)
+					gotAuth = fmt.Sprint(credentials.CustomerID, " ", credentials.APIKey)
+					w.Write([]byte(`{"token":"renewed","customer_id":1}`))
+					return
+				}
+				if r.Header.Get("X-Auth-Token") != "renewed" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte(`{"input_used":1}`))
+			}, options...)
+			for customerID, apiKey := range test.credentials {
+				client.SetCredentials(customerID, apiKey)
+			}
+
+			auth := &Token{Token: "old", CustomerID: 1, apiKey: "original"}
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+			if gotAuth != test.wantAuth {
+				t.Errorf("renewed with %q, want %q", gotAuth, test.wantAuth)
+			}
+		})
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	tests := []struct {
+		name          string
+		contentLength int64
+		wantCap       int
+	}{
+		{"unknown length", -1, 0},
+		{"exact length", int64(len(body)), len(body)},
+		{"short length", 10, 0},
+		{"long length", 2000, 2000},
+		{"bogus length", maxPresizedBody + 1, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readBody(strings.NewReader(body), test.contentLength)
+			if err != nil {
+				t.Fatalf("readBody: %v", err)
+			}
+			if string(got) != body {
+				t.Errorf("got %d bytes, want %d", len(got), len(body))
+			}
+			if test.wantCap > 0 && cap(got) < test.wantCap {
+				t.Errorf("cap = %d, want at least %d", cap(got), test.wantCap)
+			}
+			if test.contentLength > maxPresizedBody && cap(got) > 2*len(body)+bytes.MinRead {
+				t.Errorf("cap = %d, allocated for the bogus length", cap(got))
+			}
+		})
+	}
+}
+
+func TestTruncatedBody(t *testing.T) {
+	// The connection is closed after a part of the announced body, which decodes fine on its own.
+	partial := `{"input_used":1,"predictions":[{"trait":"BIG5_Openness","value":0.5}]}`
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(partial)+100, partial)
+		buf.Flush()
+	})
+
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), EndpointLikeIDs) {
+		t.Errorf("error %q does not mention the endpoint", err)
+	}
+	if !predictions.IsEmpty() {
+		t.Errorf("predictions = %v, want nothing decoded from the partial body", predictions)
+	}
+}
+
+func BenchmarkReadBody(b *testing.B) {
+	body := strings.Repeat(`{"trait":"BIG5_Openness","value":0.5},`, 2000)
+	for _, contentLength := range []int64{-1, int64(len(body))} {
+		b.Run(fmt.Sprint("content length ", contentLength), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := readBody(io.LimitReader(strings.NewReader(body), int64(len(body))), contentLength); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPredictLikeIDs(b *testing.B) {
+	response := []byte(`{"input_used":3,"predictions":[` + strings.Repeat(`{"trait":"BIG5_Openness","value":0.5},`, 500) + `{"trait":"Age","value":30}]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(response)
+	}))
+	defer server.Close()
+	client, err := NewClient(WithBaseURL(server.URL))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ids := []string{"1", "2", "3"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.PredictLikeIDs(context.Background(), ids, nil, StubToken()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		want    string
+		wantErr bool
+	}{
+		{"default", nil, ProductionURL, false},
+		{"production", []ClientOption{WithEnvironment(EnvProduction)}, "https://api.applymagicsauce.com", false},
+		{"staging", []ClientOption{WithEnvironment(EnvStaging)}, "https://api-staging.applymagicsauce.com", false},
+		{"base url after environment", []ClientOption{WithEnvironment(EnvStaging), WithBaseURL("http://localhost:8080/")}, "http://localhost:8080", false},
+		{"base url before environment", []ClientOption{WithBaseURL("http://localhost:8080"), WithEnvironment(EnvStaging)}, "http://localhost:8080", false},
+		{"unknown environment", []ClientOption{WithEnvironment(Environment(7))}, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(test.options...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewClient: %v, want error: %v", err, test.wantErr)
+			}
+			if err == nil && client.baseURL != test.want {
+				t.Errorf("base url = %s, want %s", client.baseURL, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		customerID int
+		apiKey     string
+		globalKey  string
+		wantKey    string
+	}{
+		{"valid", 7, "key", "", "key"},
+		{"zero customer id", 0, "key", "", ""},
+		{"negative customer id", -1, "key", "", ""},
+		{"empty key", 7, "", "", ""},
+		{"empty key with a global key", 7, "", "global", "global"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(key string, set bool) { sharedAPIKey, apiKeySet = key, set }(sharedAPIKey, apiKeySet)
+			SetAPIKey(test.globalKey)
+
+			var sent struct {
+				CustomerID int    `json:"customer_id"`
+				APIKey     string `json:"api_key"`
+			}
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&sent)
+				w.Write([]byte(`{"token":"t","customer_id":7}`))
+			})
+
+			token, err := client.Auth(context.Background(), test.customerID, test.apiKey)
+			if test.wantKey == "" {
+				if !errors.Is(err, ErrInvalidCredentials) || sent.CustomerID != 0 {
+					t.Errorf("err = %v, request sent: %t; want ErrInvalidCredentials without a request", err, sent.CustomerID != 0)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent.CustomerID != test.customerID || sent.APIKey != test.wantKey || token.apiKey != test.wantKey {
+				t.Errorf("sent %+v, token key %q; want customer %d and key %q", sent, token.apiKey, test.customerID, test.wantKey)
+			}
+		})
+	}
+}
+
+func TestAuthWithoutToken(t *testing.T) {
+	for _, body := range []string{
+		`{"customer_id":1}`,
+		`{"token":"","customer_id":1}`,
+		`{"error":"api key revoked"}`,
+	} {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+		token, err := client.Auth(context.Background(), 1, "key")
+		if !errors.Is(err, ErrAuthFailure) || token != nil {
+			t.Errorf("%s: Auth = %v, %v, want ErrAuthFailure", body, token, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), body) {
+			t.Errorf("%s: error %q does not include the body", body, err)
+		}
+	}
+}
+
+func TestAutoRenew(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []ClientOption
+		globalKey string
+		wantRenew bool
+	}{
+		{"default", nil, "", false},
+		{"default with APIKey", nil, "global", true},
+		{"enabled", []ClientOption{WithAutoRenew(true)}, "", true},
+		{"disabled", []ClientOption{WithAutoRenew(false)}, "global", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(key string, set bool) { sharedAPIKey, apiKeySet = key, set }(sharedAPIKey, apiKeySet)
+			SetAPIKey(test.globalKey)
+			var renewals int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == EndpointAuth {
+					renewals++
+					w.Write([]byte(`{"token":"renewed","customer_id":1}`))
+					return
+				}
+				if r.Header.Get("X-Auth-Token") != "renewed" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte(`{"input_used":1}`))
+			}, test.options...)
+
+			auth := &Token{Token: "old", CustomerID: 1, apiKey: "key"}
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth)
+			if test.wantRenew {
+				if err != nil || renewals != 1 || auth.Token != "renewed" {
+					t.Errorf("err = %v after %d renewals, token %q; want one renewal", err, renewals, auth.Token)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTokenExpired) || renewals != 0 {
+				t.Errorf("err = %v after %d renewals, want ErrTokenExpired without renewal", err, renewals)
+			}
+		})
+	}
+}
+
+func TestNoRenew(t *testing.T) {
+	var renewals int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			renewals++
+			w.Write([]byte(`{"token":"renewed","customer_id":1}`))
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "renewed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithAutoRenew(true))
+	auth := &Token{Token: "old", CustomerID: 1, apiKey: "key"}
+
+	ctx := WithNoRenew(context.Background())
+	if _, err := client.PredictText(ctx, "text", MinimalBigFiveOptions(SourceOther), auth); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("PredictText: err = %v, want ErrTokenExpired", err)
+	}
+	if _, err := client.PredictLikeIDs(ctx, []string{"1"}, nil, auth); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("PredictLikeIDs: err = %v, want ErrTokenExpired", err)
+	}
+	if renewals != 0 || auth.Token != "old" {
+		t.Errorf("%d renewals with WithNoRenew, token %q; want none", renewals, auth.Token)
+	}
+
+	// Other calls still renew.
+	if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth); err != nil || renewals != 1 {
+		t.Errorf("err = %v after %d renewals, want one renewal", err, renewals)
+	}
+}
+
+func TestTokenProvider(t *testing.T) {
+	errProvider := errors.New("auth service unavailable")
+	tests := []struct {
+		name      string
+		provide   func(ctx context.Context) (*Token, error)
+		wantErr   error
+		wantToken string
+	}{
+		{"token", func(ctx context.Context) (*Token, error) { return &Token{Token: "provided", CustomerID: 1}, nil }, nil, "provided"},
+		{"error", func(ctx context.Context) (*Token, error) { return nil, errProvider }, errProvider, "old"},
+		{"no token", func(ctx context.Context) (*Token, error) { return nil, nil }, nil, "old"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			var sentTokens []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == EndpointAuth {
+					t.Error("token renewed with Auth")
+				}
+				sentTokens = append(sentTokens, r.Header.Get("X-Auth-Token"))
+				if r.Header.Get("X-Auth-Token") != "provided" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte(`{"input_used":1}`))
+			}, WithTokenProvider(func(ctx context.Context) (*Token, error) {
+				calls++
+				return test.provide(ctx)
+			}))
+
+			auth := &Token{Token: "old", CustomerID: 1, apiKey: "key"}
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth)
+			switch {
+			case test.wantToken == "provided" && err != nil:
+				t.Errorf("err = %v", err)
+			case test.wantErr != nil && !errors.Is(err, test.wantErr):
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			case test.wantToken == "old" && err == nil:
+				t.Error("call succeeded without a token")
+			}
+			if calls != 1 {
+				t.Errorf("provider called %d times, want once", calls)
+			}
+			if auth.Token != test.wantToken {
+				t.Errorf("token = %q, want %q", auth.Token, test.wantToken)
+			}
+			if test.wantToken == "provided" && !reflect.DeepEqual(sentTokens, []string{"old", "provided"}) {
+				t.Errorf("sent tokens %v, want the provided one for the retry", sentTokens)
+			}
+		})
+	}
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	withDeadline := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 50*time.Millisecond)
+	}
+	tests := []struct {
+		name    string
+		options []ClientOption
+		ctx     func() (context.Context, context.CancelFunc)
+	}{
+		{"default deadline", []ClientOption{WithDefaultDeadline(50 * time.Millisecond)}, nil},
+		{"context deadline", []ClientOption{WithDefaultDeadline(time.Hour)}, withDeadline},
+		{"http client timeout", []ClientOption{WithDefaultDeadline(time.Hour), WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond})}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The server never responds.
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			}, test.options...)
+
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if test.ctx != nil {
+				ctx, cancel = test.ctx()
+			}
+			defer cancel()
+
+			start := time.Now()
+			_, err := client.PredictLikeIDs(ctx, []string{"1"}, nil, StubToken())
+			if err == nil {
+				t.Fatal("call to a server that never responds succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("call took %s, want it to end after about 50ms", elapsed)
+			}
+		})
+	}
+}
+
+// TestSetAPIKeyConcurrent is meant to be run with -race.
+func TestSetAPIKeyConcurrent(t *testing.T) {
+	defer func(key string, set bool) { sharedAPIKey, apiKeySet = key, set }(sharedAPIKey, apiKeySet)
+	SetAPIKey("key 0")
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			w.Write([]byte(`{"token":"renewed","customer_id":1}`))
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "renewed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			SetAPIKey(fmt.Sprint("key ", i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			// Both calls read the global key: Auth without a key and the renewal of a token that was
+			// not returned by Auth.
+			if _, err := client.Auth(context.Background(), 1, ""); err != nil {
+				t.Errorf("Auth: %v", err)
+			}
+			if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, &Token{Token: "old", CustomerID: 1}); err != nil {
+				t.Errorf("PredictLikeIDs: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if key := globalAPIKey(); !strings.HasPrefix(key, "key ") {
+		t.Errorf("globalAPIKey() = %q, want one of the keys set", key)
+	}
+}
+
+func TestRenewalCredentials(t *testing.T) {
+	defer func(key string, set bool) { sharedAPIKey, apiKeySet = key, set }(sharedAPIKey, apiKeySet)
+
+	var renewedWith []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			var credentials struct {
+				APIKey string `json:"api_key"`
+			}
+			json.NewDecoder(r.Body).Decode(&credentials)
+			renewedWith = append(renewedWith, credentials.APIKey)
+			fmt.Fprintf(w, `{"token":"token-%d","customer_id":1}`, len(renewedWith))
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("X-Auth-Token"), "token-") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithAutoRenew(true))
+
+	SetAPIKey("original")
+	auth, err := client.Auth(context.Background(), 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The global key changes after the token was obtained.
+	SetAPIKey("stale")
+	auth.Token = "expired"
+	if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"original", "original"}; !reflect.DeepEqual(renewedWith, want) {
+		t.Errorf("authenticated with %v, want %v", renewedWith, want)
+	}
+
+	// Without any key, renewal fails instead of authenticating with an empty one.
+	SetAPIKey("")
+	renewedWith = nil
+	_, err = client.PredictLikeIDs(context.Background(), []string{"1"}, nil, &Token{Token: "other", CustomerID: 1})
+	if !errors.Is(err, ErrInvalidCredentials) || len(renewedWith) != 0 {
+		t.Errorf("err = %v after %d renewals, want ErrInvalidCredentials without a request", err, len(renewedWith))
+	}
+}
+
+func TestRenewalUsesClient(t *testing.T) {
+	var authPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, EndpointAuth) {
+			authPath = r.URL.Path
+			w.Write([]byte(`{"token":"renewed","customer_id":1}`))
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "renewed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}))
+	defer server.Close()
+
+	var hosts []string
+	transport := transportFunc(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	client, err := NewClient(WithBaseURL(server.URL), WithAPIVersion("v2"), WithHTTPClient(&http.Client{Transport: transport}), WithAutoRenew(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &Token{Token: "old", CustomerID: 1, apiKey: "key"}
+	if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth); err != nil {
+		t.Fatal(err)
+	}
+	serverHost := strings.TrimPrefix(server.URL, "http://")
+	if want := []string{serverHost, serverHost, serverHost}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("requests sent to %v through the transport of the Client, want %v", hosts, want)
+	}
+	if authPath != "/v2"+EndpointAuth {
+		t.Errorf("renewed at %q, want the auth endpoint of the Client", authPath)
+	}
+}
+
+// transportFunc is an http.RoundTripper calling itself.
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestRenewalCanceled(t *testing.T) {
+	authStarted := make(chan struct{}, 1)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != EndpointAuth {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		authStarted <- struct{}{}
+		<-r.Context().Done()
+	}, WithAutoRenew(true))
+
+	auth := StubToken()
+	auth.CustomerID, auth.apiKey = 1, "key"
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-authStarted
+		cancel()
+	}()
+
+	_, err := client.PredictLikeIDs(ctx, []string{"1"}, nil, auth)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if auth.Token != stubTokenValue {
+		t.Errorf("token = %q, want it unchanged", auth.Token)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request sent in dry-run mode: %s", r.URL)
+	}, WithDryRun(true))
+	ctx := context.Background()
+
+	token, err := client.Auth(ctx, 3, "key")
+	if err != nil || !token.DryRun || token.CustomerID != 3 {
+		t.Errorf("Auth = %v, %v, want a dry-run token", token, err)
+	}
+	predictions, err := client.PredictText(ctx, "text", MinimalBigFiveOptions(SourceOther), token)
+	if err != nil || predictions.Source != SourceDryRun {
+		t.Errorf("PredictText = %+v, %v, want a dry-run result", predictions, err)
+	}
+	predictions, err = client.PredictLikeIDs(ctx, []string{"1"}, nil, token)
+	if err != nil || predictions.Source != SourceDryRun {
+		t.Errorf("PredictLikeIDs = %+v, %v, want a dry-run result", predictions, err)
+	}
+
+	// Everything but the request itself is still checked.
+	invalid := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"invalid credentials", func() error { _, err := client.Auth(ctx, 0, "key"); return err }, ErrInvalidCredentials},
+		{"missing source", func() error { _, err := client.PredictText(ctx, "text", url.Values{}, token); return err }, ErrMissingSource},
+		{"unsupported contributors", func() error {
+			_, err := client.PredictText(ctx, "text", PredictTextOptionsWithContributors(SourceOther, nil, false, true), token)
+			return err
+		}, ErrContributorsNotSupported},
+	}
+	for _, test := range invalid {
+		if err := test.call(); !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package applymagicsauce
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSONCodec encodes and decodes the JSON payloads of the API. See WithJSONCodec.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// WithJSONCodec sets the JSONCodec used for all requests and responses. The default uses
+// encoding/json. This allows plugging in a faster implementation (e.g. jsoniter or goccy/go-json)
+// without this package depending on it. Predictions and Token are decoded with the codec as well,
+// even though their UnmarshalJSON methods use encoding/json.
+func WithJSONCodec(codec JSONCodec) ClientOption {
+	return func(c *Client) error {
+		if codec == nil {
+			return fmt.Errorf("json codec must not be nil")
+		}
+		c.codec = codec
+		return nil
+	}
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// codecDecoder is implemented by the types with an UnmarshalJSON method. A codec would call that
+// method, i.e. decode them with encoding/json, so they decode themselves with the codec instead.
+type codecDecoder interface {
+	decodeWith(codec JSONCodec, data []byte) error
+}
+
+// unmarshal decodes data into v with the codec of the Client.
+func (c *Client) unmarshal(data []byte, v interface{}) error {
+	if decoder, ok := v.(codecDecoder); ok {
+		if _, std := c.codec.(stdCodec); !std {
+			return decoder.decodeWith(c.codec, data)
+		}
+	}
+	return c.codec.Unmarshal(data, v)
+}
@@ -0,0 +1,169 @@
+package applymagicsauce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCodec is the default codec that counts how often it is used.
+type countingCodec struct {
+	stdCodec
+	marshals, unmarshals int32
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	atomic.AddInt32(&c.marshals, 1)
+	return c.stdCodec.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	atomic.AddInt32(&c.unmarshals, 1)
+	return c.stdCodec.Unmarshal(data, v)
+}
+
+func TestJSONCodec(t *testing.T) {
+	codec := &countingCodec{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := io.ReadAll(r.Body); string(body) != `["1","2"]` {
+			t.Errorf("body = %s", body)
+		}
+		w.Write([]byte(`{"input_used":2}`))
+	}, WithJSONCodec(codec))
+
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1", "2"}, nil, StubToken())
+	if err != nil || predictions.InputUsed != 2 {
+		t.Fatalf("PredictLikeIDs = %+v, %v", predictions, err)
+	}
+	// The response is split into its fields, and input_used decoded.
+	if codec.marshals != 1 || codec.unmarshals != 2 {
+		t.Errorf("codec used for %d marshals and %d unmarshals, want 1 and 2", codec.marshals, codec.unmarshals)
+	}
+
+	if _, err := NewClient(WithJSONCodec(nil)); err == nil {
+		t.Error("NewClient accepted a nil codec")
+	}
+}
+
+// typeCodec is the default codec that records the types it decodes.
+type typeCodec struct {
+	stdCodec
+	mu    sync.Mutex
+	types map[string]bool
+}
+
+func (c *typeCodec) Unmarshal(data []byte, v interface{}) error {
+	c.mu.Lock()
+	c.types[fmt.Sprintf("%T", v)] = true
+	c.mu.Unlock()
+	return c.stdCodec.Unmarshal(data, v)
+}
+
+func TestJSONCodecTypes(t *testing.T) {
+	const response = `{"input_used":2,"model_version":"v3","notice":"new model",` +
+		`"predictions":[{"trait":"Age","value":30}],` +
+		`"interpretations":[{"trait":"Age","value":12345678901234567890}],` +
+		`"contributors":[{"trait":"Age","positive":["1"],"negative":["2"]}]}`
+	codec := &typeCodec{types: make(map[string]bool)}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			w.Write([]byte(`{"token":"issued","customer_id":1,"expires":1700000000000}`))
+			return
+		}
+		w.Write([]byte(response))
+	}, WithJSONCodec(codec))
+
+	token, err := client.Auth(context.Background(), 1, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.ExpiresAt().Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("ExpiresAt = %s, want the expiry of the response", token.ExpiresAt())
+	}
+
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1", "2"}, nil, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Predictions{Source: predictions.Source, Submitted: predictions.Submitted, InputRef: predictions.InputRef}
+	if err := json.Unmarshal([]byte(response), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(predictions, want) {
+		t.Errorf("decoded with the codec:\n%#v\nwant the same as with encoding/json:\n%#v", predictions, want)
+	}
+
+	// The types with an UnmarshalJSON method are never handed to the codec, it would call the method.
+	for _, typ := range []string{"*applymagicsauce.Token", "*applymagicsauce.Predictions"} {
+		if codec.types[typ] {
+			t.Errorf("%s decoded by its UnmarshalJSON method", typ)
+		}
+	}
+	for _, typ := range []string{"*applymagicsauce.token", "*[]applymagicsauce.PredictionEntry", "*[]applymagicsauce.ContributorEntry"} {
+		if !codec.types[typ] {
+			t.Errorf("%s not decoded with the codec, got %v", typ, codec.types)
+		}
+	}
+}
+
+// cannedCodec stands in for a fast codec: it decodes every response into the same fields, and the
+// predictions into the same entries.
+type cannedCodec struct {
+	stdCodec
+	fields      map[string]json.RawMessage
+	predictions []PredictionEntry
+}
+
+func (c cannedCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *map[string]json.RawMessage:
+		*v = c.fields
+	case *[]PredictionEntry:
+		*v = c.predictions
+	default:
+		return c.stdCodec.Unmarshal(data, v)
+	}
+	return nil
+}
+
+func BenchmarkJSONCodec(b *testing.B) {
+	response := []byte(`{"input_used":3,"predictions":[` + strings.Repeat(`{"trait":"BIG5_Openness","value":0.5},`, 1000) + `{"trait":"Age","value":30}]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	codecs := []struct {
+		name  string
+		codec JSONCodec
+	}{
+		{"encoding/json", stdCodec{}},
+		{"canned", cannedCodec{
+			fields:      map[string]json.RawMessage{"input_used": json.RawMessage("3"), "predictions": nil},
+			predictions: []PredictionEntry{{Trait: TraitAge, Value: 30}},
+		}},
+	}
+	for _, codec := range codecs {
+		b.Run(codec.name, func(b *testing.B) {
+			client, err := NewClient(WithBaseURL(server.URL), WithJSONCodec(codec.codec))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// WithMinContributorIDs sets the minimum number of Like IDs for which PredictLikeIDs requests
+// contributors. With fewer Like IDs the API has too little input to name meaningful contributors and
+// returns none or fails, so OptionsContributors is dropped from such calls instead. The predictions are
+// made as usual and have Predictions.ContributorsDropped set; the Client also logs a warning (see
+// WithLogger). A value of zero, the default, always sends the option.
+func WithMinContributorIDs(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("minimum number of like ids for contributors must not be negative")
+		}
+		c.minContributorIDs = n
+		return nil
+	}
+}
+
+// dropContributors removes OptionsContributors from options if they are requested for fewer Like IDs
+// than set with WithMinContributorIDs. options is not modified.
+func (c *Client) dropContributors(ctx context.Context, count int, options url.Values) (url.Values, bool) {
+	if count >= c.minContributorIDs {
+		return options, false
+	}
+	if enabled, _ := strconv.ParseBool(options.Get(OptionsContributors)); !enabled {
+		return options, false
+	}
+
+	c.logf(ctx, "not requesting contributors for %d like ids (minimum is %d)", count, c.minContributorIDs)
+	options = cloneValues(options)
+	options.Del(OptionsContributors)
+	return options, true
+}
@@ -0,0 +1,58 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMinContributorIDs(t *testing.T) {
+	tests := []struct {
+		name         string
+		ids          int
+		contributors bool
+		wantSent     bool
+		wantDropped  bool
+	}{
+		{"below the minimum", 4, true, false, true},
+		{"at the minimum", 5, true, true, false},
+		{"above the minimum", 6, true, true, false},
+		{"not requested", 4, false, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var query string
+			logger := &lineLogger{}
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.RawQuery
+				w.Write([]byte(`{"input_used":1}`))
+			}, WithMinContributorIDs(5), WithLogger(logger))
+
+			ids := strings.Split(strings.Repeat("1,", test.ids-1)+"1", ",")
+			options := PredictLikeIDsOptions(nil, false, test.contributors)
+			predictions, err := client.PredictLikeIDs(context.Background(), ids, options, StubToken())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent := strings.Contains(query, OptionsContributors); sent != test.wantSent {
+				t.Errorf("query %q, want contributors sent: %v", query, test.wantSent)
+			}
+			if predictions.ContributorsDropped != test.wantDropped {
+				t.Errorf("ContributorsDropped = %v, want %v", predictions.ContributorsDropped, test.wantDropped)
+			}
+			if _, ok := options[OptionsContributors]; ok != test.contributors {
+				t.Error("the options of the caller were modified")
+			}
+
+			var warned bool
+			for _, line := range logger.take() {
+				warned = warned || strings.Contains(line, "not requesting contributors for 4 like ids")
+			}
+			if warned != test.wantDropped {
+				t.Errorf("logged the dropped contributors: %v, want %v", warned, test.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package applymagicsauce
+
+// EstimateCost returns the number of calls to the prediction endpoints predicting input would count
+// against the usage limits (see Token.UsageFor), e.g. to check a large job against the calls that are
+// still available before starting it. A text and a set of Like IDs cost one call each, unless the
+// Like IDs are split by WithLikeIDBatching, which costs one call per batch.
+//
+// The estimate assumes that every call reaches the API, so results answered from the cache and
+// retries are not taken into account. With WithDryRun, the cost is always zero. Input that would be
+// rejected before sending, e.g. too many Like IDs without batching, results in the same error the
+// call would return.
+func (c *Client) EstimateCost(input ProfileInput) (cost int, err error) {
+	if c.dryRun {
+		return 0, nil
+	}
+
+	if input.Text != "" {
+		cost++
+	}
+	if len(input.LikeIDs) > 0 {
+		if err = c.checkLikeIDCount(len(input.LikeIDs)); err != nil {
+			if !c.batchLikeIDs {
+				return 0, err
+			}
+			cost += (len(input.LikeIDs) + c.maxLikeIDs - 1) / c.maxLikeIDs
+		} else {
+			cost++
+		}
+	}
+	return cost, nil
+}
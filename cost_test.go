@@ -0,0 +1,57 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	ids := []string{"1", "2", "3", "4", "5", "6", "7"}
+	batching := []ClientOption{WithMaxLikeIDs(3), WithLikeIDBatching(true)}
+	tests := []struct {
+		name     string
+		options  []ClientOption
+		input    ProfileInput
+		wantCost int
+		wantErr  error
+	}{
+		{"nothing", nil, ProfileInput{}, 0, nil},
+		{"text", nil, ProfileInput{Text: "text"}, 1, nil},
+		{"like ids", nil, ProfileInput{LikeIDs: ids}, 1, nil},
+		{"three batches", batching, ProfileInput{LikeIDs: ids}, 3, nil},
+		{"text and batches", batching, ProfileInput{Text: "text", LikeIDs: ids}, 4, nil},
+		{"too many like ids", []ClientOption{WithMaxLikeIDs(3)}, ProfileInput{LikeIDs: ids}, 0, ErrTooManyLikeIDs},
+		{"dry run", append([]ClientOption{WithDryRun(true)}, batching...), ProfileInput{Text: "text", LikeIDs: ids}, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte(`{"input_used":1}`))
+			}, test.options...)
+
+			cost, err := client.EstimateCost(test.input)
+			if cost != test.wantCost || !errors.Is(err, test.wantErr) {
+				t.Fatalf("EstimateCost = %d, %v, want %d, %v", cost, err, test.wantCost, test.wantErr)
+			}
+			if requests != 0 {
+				t.Fatalf("EstimateCost sent %d requests", requests)
+			}
+
+			// The estimate matches the requests the calls send.
+			if len(test.input.LikeIDs) > 0 && err == nil {
+				client.PredictLikeIDs(context.Background(), test.input.LikeIDs, nil, StubToken())
+			}
+			if test.input.Text != "" {
+				client.PredictText(context.Background(), test.input.Text, MinimalBigFiveOptions(SourceOther), StubToken())
+			}
+			if requests != test.wantCost {
+				t.Errorf("calls sent %d requests, estimated %d", requests, test.wantCost)
+			}
+		})
+	}
+}
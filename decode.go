@@ -0,0 +1,150 @@
+package applymagicsauce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// bodySnippetLength is the number of bytes of a response body included in decoding errors.
+const bodySnippetLength = 200
+
+// ErrDuplicateTraits is returned by the predict functions of a Client with WithStrictResponses if the
+// API sent the same trait more than once. See Predictions.Duplicates.
+var ErrDuplicateTraits = errors.New("duplicate traits in response")
+
+// WithStrictResponses makes the predict functions fail with ErrDuplicateTraits if a response contains
+// the same trait more than once and with ErrInvalidPredictions if it does not pass
+// Predictions.Validate, instead of returning it as it is. The decoded Predictions are still returned
+// along with the error, but not cached. The default is to accept such responses.
+func WithStrictResponses(strict bool) ClientOption {
+	return func(c *Client) error {
+		c.strictResponses = strict
+		return nil
+	}
+}
+
+// checkPredictions returns an error if strict responses are enabled and predictions contain duplicate
+// traits or are invalid.
+func (c *Client) checkPredictions(predictions Predictions) error {
+	if !c.strictResponses {
+		return nil
+	}
+	if duplicates := predictions.Duplicates(); len(duplicates) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateTraits, strings.Join(duplicates, ", "))
+	}
+	return predictions.Validate()
+}
+
+// decode decodes the body of response into v. Errors mention the endpoint, the status code and the
+// beginning of the body, so that e.g. an HTML error page of a proxy is easy to spot.
+func (c *Client) decode(endpoint string, response *response, v interface{}) error {
+	body, err := response.body, error(nil)
+	if predictions, ok := v.(*Predictions); ok && response.streamed != nil {
+		body, err = response.prefix, response.decodeErr
+		*predictions = *response.streamed
+	} else {
+		err = c.unmarshal(response.body, v)
+	}
+	if err == nil {
+		return nil
+	}
+
+	snippet := string(body)
+	if len(body) > bodySnippetLength {
+		snippet = string(body[:bodySnippetLength]) + "..."
+	}
+	return fmt.Errorf("could not decode response of %s (status %d): %w; body: %q",
+		endpointPath(endpoint), response.statusCode, err, snippet)
+}
+
+type streamKey struct{}
+
+// withStreaming marks the requests made with ctx as prediction requests, whose successful responses
+// may be decoded straight from the connection. See streamable.
+func withStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamKey{}, true)
+}
+
+// streamable reports whether the body of resp is decoded into Predictions while it is read, instead
+// of being read into memory first, which takes as much memory as the response is large. This is only
+// possible with encoding/json, as JSONCodec works on byte slices, and not with WithRetryDecider, whose
+// function may read the body.
+func (c *Client) streamable(ctx context.Context, resp *http.Response) bool {
+	streaming, _ := ctx.Value(streamKey{}).(bool)
+	_, std := c.codec.(stdCodec)
+	return streaming && std && c.retryDecider == nil && resp.StatusCode == http.StatusOK
+}
+
+// streamResponse decodes the body of resp into Predictions. A body that can not be read completely
+// fails with the error of reading it, like in readBody; a body that does not decode is returned with
+// response.decodeErr set and only its beginning kept for the error message.
+func streamResponse(resp *http.Response) (*response, error) {
+	body := &errorRecorder{r: resp.Body}
+	prefix := &prefixWriter{max: bodySnippetLength + 1}
+	tee := io.TeeReader(body, prefix)
+	decoder := json.NewDecoder(tee)
+
+	var predictions Predictions
+	err := predictions.decodeFrom(decoder)
+	if err == nil {
+		// Like json.Unmarshal, reject anything after the predictions. This also reads the body up
+		// to its end, so that the connection can be reused.
+		if _, err = decoder.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("invalid data after the predictions")
+		}
+	}
+	if err != nil {
+		// The decoder may have stopped early, read enough of the body for the error message.
+		io.CopyN(ioutil.Discard, tee, int64(prefix.max-len(prefix.buf)))
+	}
+	if body.err != nil {
+		return nil, body.err
+	}
+	return &response{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		streamed:   &predictions,
+		decodeErr:  err,
+		prefix:     prefix.buf,
+	}, nil
+}
+
+// errorRecorder passes reads through to r and keeps the first error other than io.EOF, so that a body
+// that can not be read is told apart from one that does not decode.
+type errorRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errorRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// prefixWriter keeps the first max bytes written to it and discards the rest.
+type prefixWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if room := w.max - len(w.buf); room > 0 {
+		if len(p) > room {
+			w.buf = append(w.buf, p[:room]...)
+		} else {
+			w.buf = append(w.buf, p...)
+		}
+	}
+	return len(p), nil
+}
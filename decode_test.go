@@ -0,0 +1,122 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeHTML(t *testing.T) {
+	page := "<html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat("nginx ", 100) + "</body></html>"
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	})
+
+	calls := []struct {
+		endpoint string
+		call     func() error
+	}{
+		{EndpointAuth, func() error {
+			_, err := client.Auth(context.Background(), 1, "key")
+			return err
+		}},
+		{EndpointLikeIDs, func() error {
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+			return err
+		}},
+	}
+	for _, call := range calls {
+		err := call.call()
+		if err == nil {
+			t.Fatalf("%s: no error for an HTML body", call.endpoint)
+		}
+		message := err.Error()
+		for _, want := range []string{call.endpoint, "status 200", "<title>502 Bad Gateway</title>", "..."} {
+			if !strings.Contains(message, want) {
+				t.Errorf("%s: error %q does not mention %q", call.endpoint, message, want)
+			}
+		}
+		if strings.Contains(message, "</html>") {
+			t.Errorf("%s: error contains the whole body", call.endpoint)
+		}
+	}
+}
+
+func TestStreamResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        io.Reader
+		wantErr     error
+		wantDecoded bool
+	}{
+		{"predictions", strings.NewReader(`{"input_used":1,"predictions":[{"trait":"Age","value":30}]}` + "\n"), nil, true},
+		{"null", strings.NewReader(`null`), nil, true},
+		{"data after the predictions", strings.NewReader(`{"input_used":1}{"input_used":2}`), nil, false},
+		{"not json", strings.NewReader(`<html>`), nil, false},
+		{"broken connection", io.MultiReader(strings.NewReader(`{"input_used":1}`), iotest.ErrReader(io.ErrUnexpectedEOF)), io.ErrUnexpectedEOF, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := streamResponse(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(test.body)})
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if decoded := response.decodeErr == nil; decoded != test.wantDecoded {
+				t.Errorf("decode error = %v, want decoded: %v", response.decodeErr, test.wantDecoded)
+			}
+			if response.body != nil {
+				t.Errorf("body = %q, want it not to be kept", response.body)
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeLargeResponse compares decoding a large /like_ids response while reading it, as the
+// Client does with encoding/json, to reading the body first, as it does with other codecs. The
+// streamed decoding only ever holds a single list entry besides the Predictions.
+func BenchmarkDecodeLargeResponse(b *testing.B) {
+	var body bytes.Buffer
+	body.WriteString(`{"input_used":5000,"predictions":[`)
+	body.WriteString(strings.Repeat(`{"trait":"BIG5_Openness","value":0.5},`, 5000))
+	body.WriteString(`{"trait":"Age","value":30}],"contributors":[{"trait":"BIG5_Openness","positive":[`)
+	body.WriteString(strings.Repeat(`"1234567890",`, 5000))
+	body.WriteString(`"1"],"negative":[]}]}`)
+	data := body.Bytes()
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			response, err := streamResponse(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(data))})
+			if err == nil {
+				err = response.decodeErr
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("read body", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var predictions Predictions
+			buf, err := readBody(bytes.NewReader(data), int64(len(data)))
+			if err == nil {
+				err = stdCodec{}.Unmarshal(buf, &predictions)
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package applymagicsauce
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Do sends a request to an endpoint of the API this package does not wrap (yet), e.g. a new feature
+// of the provider. endpoint is a path relative to the base URL, optionally with a query. The request
+// is sent like those of Auth and the predict functions, which are built on the same path: it gets the
+// configured headers (see WithContentType, WithAccept and WithRequestModifier) and is retried and
+// limited in time according to the options of the Client.
+//
+// The authentication token is a token of the Authenticator set with WithAuthenticator or, without one,
+// a token of the function set with WithTokenProvider. Without either, the request is sent without a
+// token. With WithDryRun nothing is sent and Do returns a status of 0 without a body or an error.
+//
+// If the API answered, the status code and the body of the response are returned, even if err is an
+// *APIError because of a status code of 400 or above. Rejected tokens are not renewed.
+func (c *Client) Do(ctx context.Context, method, endpoint string, body io.Reader) (status int, respBody []byte, err error) {
+	var payload []byte
+	if body != nil {
+		// The payload is read completely, so that it can be sent again by retries.
+		if payload, err = ioutil.ReadAll(body); err != nil {
+			return 0, nil, err
+		}
+	}
+	if c.dryRun {
+		return 0, nil, nil
+	}
+
+	auth, err := c.authenticated(ctx, nil)
+	if err == nil && auth == nil && c.tokenProvider != nil {
+		auth, err = c.tokenProvider(ctx)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	response, err := c.do(ctx, method, endpoint, payload, nil, auth)
+	if response == nil {
+		return 0, nil, err
+	}
+	return response.statusCode, response.body, err
+}
+
+// do sends a request with doRequest and classifies the response with classifyResponse. If the API
+// answered, the response is returned along with the *APIError of a failure status, so that callers can
+// act on the status code.
+func (c *Client) do(ctx context.Context, method, endpoint string, payload []byte, header http.Header, auth *Token) (*response, error) {
+	response, err := c.doRequest(ctx, method, endpoint, payload, header, auth)
+	if err != nil {
+		return nil, err
+	}
+	return response, c.classifyResponse(endpoint, response)
+}
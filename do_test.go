@@ -0,0 +1,83 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	provider := WithTokenProvider(func(ctx context.Context) (*Token, error) {
+		return &Token{Token: "provided"}, nil
+	})
+	tests := []struct {
+		name       string
+		options    []ClientOption
+		statuses   []int
+		wantToken  string
+		wantStatus int
+		wantCalls  int
+		wantErr    error
+	}{
+		{"token provider", []ClientOption{provider}, []int{http.StatusOK}, "provided", http.StatusOK, 1, nil},
+		{"no token", nil, []int{http.StatusOK}, "", http.StatusOK, 1, nil},
+		{"retry", []ClientOption{provider, WithRetry(2, 0)}, []int{http.StatusServiceUnavailable, http.StatusOK}, "provided", http.StatusOK, 2, nil},
+		{"rejected token", []ClientOption{provider}, []int{http.StatusForbidden}, "provided", http.StatusForbidden, 1, ErrTokenExpired},
+		{"dry run", []ClientOption{provider, WithDryRun(true)}, nil, "", 0, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if r.Method != http.MethodPut || r.URL.Path != "/new" {
+					t.Errorf("got %s %s, want PUT /new", r.Method, r.URL.Path)
+				}
+				if token := r.Header.Get("X-Auth-Token"); token != test.wantToken {
+					t.Errorf("X-Auth-Token = %q, want %q", token, test.wantToken)
+				}
+				if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+					t.Errorf("body = %q, want payload", body)
+				}
+				w.WriteHeader(test.statuses[calls-1])
+				w.Write([]byte("answer"))
+			}, test.options...)
+
+			status, body, err := client.Do(context.Background(), http.MethodPut, "/new", strings.NewReader("payload"))
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+			if status != test.wantStatus {
+				t.Errorf("status = %d, want %d", status, test.wantStatus)
+			}
+			if test.wantStatus != 0 && string(body) != "answer" {
+				t.Errorf("body = %q, want the body of the response", body)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("%d requests sent, want %d", calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoAuthenticator(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			w.Write([]byte(`{"token":"issued","customer_id":1}`))
+			return
+		}
+		if token := r.Header.Get("X-Auth-Token"); token != "issued" {
+			t.Errorf("X-Auth-Token = %q, want the token of the Authenticator", token)
+		}
+	}
+	authenticator := NewAuthenticator(newTestClient(t, handler), Credential{CustomerID: 1, APIKey: "key"}, nil)
+	client := newTestClient(t, handler, WithAuthenticator(authenticator))
+
+	if _, _, err := client.Do(context.Background(), http.MethodGet, "/new", nil); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+}
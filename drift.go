@@ -0,0 +1,66 @@
+package applymagicsauce
+
+import (
+	"math"
+	"sort"
+)
+
+// TraitDrift is the change of the predicted value of a single trait. See CompareProfiles.
+type TraitDrift struct {
+	Trait string
+	Old   float64
+	New   float64
+	// Delta is New - Old.
+	Delta float64
+}
+
+// DriftReport lists the differences between two sets of predictions. All lists are sorted by trait.
+type DriftReport struct {
+	// Drifted are the traits whose values moved by more than the tolerance.
+	Drifted []TraitDrift
+	// Added are the traits only predicted in the current predictions.
+	Added []string
+	// Removed are the traits only predicted in the baseline.
+	Removed []string
+}
+
+// HasDrift reports whether the report lists any differences.
+func (r DriftReport) HasDrift() bool {
+	return len(r.Drifted) > 0 || len(r.Added) > 0 || len(r.Removed) > 0
+}
+
+// CompareProfiles compares the predictions for the same input at different times, e.g. to detect
+// changes of the model of the provider. Traits whose values differ by more than tolerance are reported
+// as drifted, traits predicted in only one of them as added or removed.
+func CompareProfiles(baseline, current Predictions, tolerance float64) (report DriftReport) {
+	old := make(map[string]float64, len(baseline.Predictions))
+	for _, prediction := range baseline.Predictions {
+		old[prediction.Trait] = prediction.Value
+	}
+
+	seen := make(map[string]bool, len(current.Predictions))
+	for _, prediction := range current.SortedByTrait() {
+		seen[prediction.Trait] = true
+		value, ok := old[prediction.Trait]
+		if !ok {
+			report.Added = append(report.Added, prediction.Trait)
+			continue
+		}
+		if delta := prediction.Value - value; math.Abs(delta) > tolerance {
+			report.Drifted = append(report.Drifted, TraitDrift{
+				Trait: prediction.Trait,
+				Old:   value,
+				New:   prediction.Value,
+				Delta: delta,
+			})
+		}
+	}
+
+	for trait := range old {
+		if !seen[trait] {
+			report.Removed = append(report.Removed, trait)
+		}
+	}
+	sort.Strings(report.Removed)
+	return report
+}
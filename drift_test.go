@@ -0,0 +1,41 @@
+package applymagicsauce
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareProfiles(t *testing.T) {
+	profile := func(entries ...PredictionEntry) Predictions {
+		return Predictions{Predictions: entries}
+	}
+	baseline := profile(PredictionEntry{TraitOpenness, 0.5}, PredictionEntry{TraitAge, 30}, PredictionEntry{TraitFemale, 0.25})
+
+	tests := []struct {
+		name    string
+		current Predictions
+		want    DriftReport
+	}{
+		{"stable", profile(PredictionEntry{TraitFemale, 0.25}, PredictionEntry{TraitOpenness, 0.5}, PredictionEntry{TraitAge, 30}), DriftReport{}},
+		{"within tolerance", profile(PredictionEntry{TraitOpenness, 0.55}, PredictionEntry{TraitAge, 29.95}, PredictionEntry{TraitFemale, 0.25}), DriftReport{}},
+		{"drifted", profile(PredictionEntry{TraitOpenness, 0.75}, PredictionEntry{TraitAge, 26}, PredictionEntry{TraitFemale, 0.25}), DriftReport{
+			Drifted: []TraitDrift{{TraitAge, 30, 26, -4}, {TraitOpenness, 0.5, 0.75, 0.25}},
+		}},
+		{"added and removed", profile(PredictionEntry{TraitOpenness, 0.5}, PredictionEntry{TraitReligion, 0.5}, PredictionEntry{TraitPolitics, 0.5}), DriftReport{
+			Added:   []string{TraitPolitics, TraitReligion},
+			Removed: []string{TraitAge, TraitFemale},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := CompareProfiles(baseline, test.current, 0.1)
+			if !reflect.DeepEqual(report, test.want) {
+				t.Errorf("CompareProfiles = %+v, want %+v", report, test.want)
+			}
+			if drift := report.HasDrift(); drift != !reflect.DeepEqual(test.want, DriftReport{}) {
+				t.Errorf("HasDrift = %v", drift)
+			}
+		})
+	}
+}
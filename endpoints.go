@@ -0,0 +1,88 @@
+package applymagicsauce
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Endpoints of the API. They identify the endpoints throughout the package (e.g. in Stats or
+// WithContentType), independent of the paths configured with WithEndpoints or WithAPIVersion.
+const (
+	EndpointAuth    = "/auth"
+	EndpointText    = "/text"
+	EndpointLikeIDs = "/like_ids"
+
+	// EndpointTraits is an endpoint listing the supported traits. The API does not offer it (yet), so it
+	// is only used if a path is configured with WithEndpoints. See Client.SupportedTraits.
+	EndpointTraits = "/traits"
+)
+
+// Endpoints holds the paths of the endpoints of the API, relative to the base URL.
+type Endpoints struct {
+	Auth    string
+	Text    string
+	LikeIDs string
+	// Traits is empty by default, since the API has no such endpoint. See EndpointTraits.
+	Traits string
+}
+
+// DefaultEndpoints are the paths of the current version of the API.
+var DefaultEndpoints = Endpoints{
+	Auth:    EndpointAuth,
+	Text:    EndpointText,
+	LikeIDs: EndpointLikeIDs,
+}
+
+// WithEndpoints sets the paths of the endpoints. Empty fields keep the default path. This allows using
+// a new version of the API without waiting for an update of this package.
+func WithEndpoints(endpoints Endpoints) ClientOption {
+	return func(c *Client) error {
+		if endpoints.Auth != "" {
+			c.endpoints.Auth = endpoints.Auth
+		}
+		if endpoints.Text != "" {
+			c.endpoints.Text = endpoints.Text
+		}
+		if endpoints.LikeIDs != "" {
+			c.endpoints.LikeIDs = endpoints.LikeIDs
+		}
+		if endpoints.Traits != "" {
+			c.endpoints.Traits = endpoints.Traits
+		}
+		return nil
+	}
+}
+
+// WithAPIVersion prefixes the paths of all endpoints with version, e.g. "v2" turns "/text" into
+// "/v2/text".
+func WithAPIVersion(version string) ClientOption {
+	return func(c *Client) error {
+		version = strings.Trim(version, "/")
+		if strings.ContainsAny(version, "?#") {
+			return fmt.Errorf("invalid api version: %s", version)
+		}
+		c.apiVersion = version
+		return nil
+	}
+}
+
+// resolveEndpoint returns the configured path for endpoint, keeping its query.
+func (c *Client) resolveEndpoint(endpoint string) string {
+	path, query := endpointPath(endpoint), endpoint[len(endpointPath(endpoint)):]
+
+	switch path {
+	case EndpointAuth:
+		path = c.endpoints.Auth
+	case EndpointText:
+		path = c.endpoints.Text
+	case EndpointLikeIDs:
+		path = c.endpoints.LikeIDs
+	case EndpointTraits:
+		path = c.endpoints.Traits
+	}
+
+	if c.apiVersion != "" {
+		path = "/" + c.apiVersion + path
+	}
+	return path + query
+}
@@ -0,0 +1,41 @@
+package applymagicsauce
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		want    []string
+	}{
+		{"defaults", nil, []string{"/auth", "/text", "/like_ids"}},
+		{"api version", []ClientOption{WithAPIVersion("/v2/")}, []string{"/v2/auth", "/v2/text", "/v2/like_ids"}},
+		{"custom paths", []ClientOption{WithEndpoints(Endpoints{Text: "/analyze/text"})}, []string{"/auth", "/analyze/text", "/like_ids"}},
+		{"custom paths with version", []ClientOption{WithAPIVersion("v3"), WithEndpoints(Endpoints{Auth: "/token"})},
+			[]string{"/v3/token", "/v3/text", "/v3/like_ids"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var paths []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+				// A body that does for both a token and predictions.
+				w.Write([]byte(`{"token":"t","customer_id":1,"input_used":1}`))
+			}, test.options...)
+
+			callEndpoints(t, client)
+			if !reflect.DeepEqual(paths, test.want) {
+				t.Errorf("requested %v, want %v", paths, test.want)
+			}
+		})
+	}
+
+	if _, err := NewClient(WithAPIVersion("v2?x=1")); err == nil {
+		t.Error("WithAPIVersion accepted a version with a query")
+	}
+}
@@ -0,0 +1,130 @@
+package applymagicsauce
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// DefaultEpsilon is the largest difference between two predicted values that Predictions.Equal
+// still considers equal.
+const DefaultEpsilon = 1e-9
+
+// Equal reports whether p and other hold the same results: the same InputUsed, the same predicted
+// values (within DefaultEpsilon), interpretations and contributors. The order of the entries does not
+// matter, but the order of the Like IDs of a contributor does. The fields set by the Client (e.g.
+// Source) are ignored.
+func (p Predictions) Equal(other Predictions) bool {
+	return p.EqualWithin(other, DefaultEpsilon)
+}
+
+// EqualWithin works like Equal, but considers predicted values equal if they differ by at most
+// epsilon.
+func (p Predictions) EqualWithin(other Predictions, epsilon float64) bool {
+	if p.InputUsed != other.InputUsed ||
+		len(p.Predictions) != len(other.Predictions) ||
+		len(p.Interpretations) != len(other.Interpretations) ||
+		len(p.Contributors) != len(other.Contributors) {
+		return false
+	}
+
+	predictions, otherPredictions := p.SortedByTrait(), other.SortedByTrait()
+	for i := range predictions {
+		if predictions[i].Trait != otherPredictions[i].Trait ||
+			math.Abs(predictions[i].Value-otherPredictions[i].Value) > epsilon {
+			return false
+		}
+	}
+
+	interpretations, otherInterpretations := sortedInterpretations(p), sortedInterpretations(other)
+	for i := range interpretations {
+		if interpretations[i] != otherInterpretations[i] {
+			return false
+		}
+	}
+
+	contributors, otherContributors := sortedContributors(p), sortedContributors(other)
+	for i := range contributors {
+		if !equalContributors(contributors[i], otherContributors[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a hex encoded SHA-256 hash of the results in p, e.g. for use as a map key. Predictions
+// that differ only in the order of their entries or in the fields set by the Client have the same
+// hash. Unlike Equal, Hash compares the predicted values exactly.
+func (p Predictions) Hash() string {
+	hash := sha256.New()
+	write := func(s string) {
+		hash.Write([]byte(s))
+		hash.Write([]byte{0})
+	}
+
+	write(strconv.Itoa(p.InputUsed))
+	for _, prediction := range p.SortedByTrait() {
+		write(prediction.Trait)
+		write(strconv.FormatFloat(prediction.Value, 'g', -1, 64))
+	}
+	write("")
+	for _, interpretation := range sortedInterpretations(p) {
+		write(interpretation)
+	}
+	write("")
+	for _, contributor := range sortedContributors(p) {
+		write(contributor.Trait)
+		for _, id := range contributor.Positive {
+			write(id)
+		}
+		write("")
+		for _, id := range contributor.Negative {
+			write(id)
+		}
+		write("")
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// sortedInterpretations returns the interpretations of p in a canonical form, sorted by trait.
+func sortedInterpretations(p Predictions) []string {
+	interpretations := make([]string, len(p.Interpretations))
+	for i, interpretation := range p.Interpretations {
+		value, err := json.Marshal(interpretation.Value)
+		if err != nil {
+			value = []byte(strconv.Quote(err.Error()))
+		}
+		interpretations[i] = interpretation.Trait + "=" + string(value)
+	}
+	sort.Strings(interpretations)
+	return interpretations
+}
+
+// sortedContributors returns a copy of the contributors of p sorted by trait.
+func sortedContributors(p Predictions) []ContributorEntry {
+	sorted := append([]ContributorEntry(nil), p.Contributors...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Trait < sorted[j].Trait
+	})
+	return sorted
+}
+
+func equalContributors(a, b ContributorEntry) bool {
+	if a.Trait != b.Trait || len(a.Positive) != len(b.Positive) || len(a.Negative) != len(b.Negative) {
+		return false
+	}
+	for i := range a.Positive {
+		if a.Positive[i] != b.Positive[i] {
+			return false
+		}
+	}
+	for i := range a.Negative {
+		if a.Negative[i] != b.Negative[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package applymagicsauce
+
+import "testing"
+
+func TestPredictionsEqual(t *testing.T) {
+	base := Predictions{
+		InputUsed:       12,
+		Predictions:     []PredictionEntry{{TraitOpenness, 0.3}, {TraitAge, 28}},
+		Interpretations: []InterpretationEntry{{TraitAge, "25-34"}, {TraitPolitics, map[string]interface{}{"label": "Liberal"}}},
+		Contributors:    []ContributorEntry{{TraitOpenness, []string{"1", "2"}, []string{"3"}}, {TraitAge, nil, []string{"4"}}},
+	}
+
+	tests := []struct {
+		name      string
+		other     Predictions
+		wantEqual bool
+		wantHash  bool
+	}{
+		{"same", base.Clone(), true, true},
+		{"reordered", Predictions{
+			InputUsed:       12,
+			Predictions:     []PredictionEntry{{TraitAge, 28}, {TraitOpenness, 0.3}},
+			Interpretations: []InterpretationEntry{{TraitPolitics, map[string]interface{}{"label": "Liberal"}}, {TraitAge, "25-34"}},
+			Contributors:    []ContributorEntry{{TraitAge, nil, []string{"4"}}, {TraitOpenness, []string{"1", "2"}, []string{"3"}}},
+			Source:          SourceCache,
+		}, true, true},
+		{"near-equal value", modified(base, func(p *Predictions) { p.Predictions[0].Value += 1e-12 }), true, false},
+		{"different value", modified(base, func(p *Predictions) { p.Predictions[0].Value += 1e-6 }), false, false},
+		{"different interpretation", modified(base, func(p *Predictions) { p.Interpretations[0].Value = "35-44" }), false, false},
+		{"reordered like ids", modified(base, func(p *Predictions) { p.Contributors[0].Positive = []string{"2", "1"} }), false, false},
+		{"missing trait", modified(base, func(p *Predictions) { p.Predictions = p.Predictions[:1] }), false, false},
+		{"different input used", modified(base, func(p *Predictions) { p.InputUsed = 13 }), false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := base.Equal(test.other); equal != test.wantEqual {
+				t.Errorf("Equal = %v, want %v", equal, test.wantEqual)
+			}
+			if equal := test.other.Equal(base); equal != test.wantEqual {
+				t.Errorf("Equal of the swapped arguments = %v, want %v", equal, test.wantEqual)
+			}
+			if sameHash := base.Hash() == test.other.Hash(); sameHash != test.wantHash {
+				t.Errorf("same Hash = %v, want %v", sameHash, test.wantHash)
+			}
+		})
+	}
+
+	if !base.EqualWithin(modified(base, func(p *Predictions) { p.Predictions[1].Value = 28.4 }), 0.5) {
+		t.Error("EqualWithin(0.5) = false for values 0.4 apart")
+	}
+}
+
+// modified returns a copy of p changed by modify.
+func modified(p Predictions, modify func(*Predictions)) Predictions {
+	clone := p.Clone()
+	modify(&clone)
+	return clone
+}
@@ -0,0 +1,86 @@
+package applymagicsauce
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError is returned if the API answered a call with an error status code.
+type APIError struct {
+	// Endpoint is the path of the endpoint that was called, e.g. "/text".
+	Endpoint string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Body is the body of the response.
+	Body []byte
+	// Attempts is the number of requests that were sent before giving up, including retries (see
+	// WithRetry).
+	Attempts int
+	// LastStatus is the status code of the last response received. It equals StatusCode, unless the
+	// last retry failed without a response; then StatusCode is zero and the APIError unwraps to the
+	// error of that retry.
+	LastStatus int
+
+	// err is a sentinel error the APIError corresponds to, if any.
+	err error
+}
+
+func (e *APIError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s (after %d attempts, last status %d)", e.message(), e.Attempts, e.LastStatus)
+	}
+	return e.message()
+}
+
+func (e *APIError) message() string {
+	switch {
+	case e.err != nil:
+		return e.err.Error()
+	case e.StatusCode == http.StatusBadRequest:
+		return fmt.Sprintf("bad request: %s", e.Body)
+	case e.StatusCode == http.StatusForbidden:
+		return "authentication failure"
+	case e.StatusCode == http.StatusNotFound:
+		return "endpoint not found"
+	case e.StatusCode == http.StatusTooManyRequests:
+		return fmt.Sprintf("usage limit exceeded: %s", e.Body)
+	case e.StatusCode == http.StatusInternalServerError:
+		return "api is temporarily not available"
+	default:
+		return fmt.Sprintf("unexpected status %d from %s: %s", e.StatusCode, e.Endpoint, e.Body)
+	}
+}
+
+// Unwrap returns the sentinel error the APIError corresponds to, e.g. ErrTokenExpired if a prediction
+// endpoint rejected the token or ErrAuthFailure if Auth was rejected, so that errors.Is works with it.
+// If retries ended without a response, it returns the error of the last retry.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// classifyResponse returns nil if the status of response means success and an *APIError otherwise.
+// endpoint is one of the Endpoint constants. An auth failure code (403 by default, see
+// WithAuthFailureCodes) of the prediction endpoints means that the token is not accepted (anymore), so
+// the error unwraps to ErrTokenExpired there. For EndpointAuth it unwraps to ErrAuthFailure.
+func (c *Client) classifyResponse(endpoint string, response *response) error {
+	status := response.statusCode
+	if status < http.StatusBadRequest {
+		return nil
+	}
+
+	apiErr := &APIError{
+		Endpoint:   endpointPath(endpoint),
+		StatusCode: status,
+		Body:       response.body,
+		Attempts:   response.attempts,
+		LastStatus: status,
+	}
+	if c.isAuthFailure(status) {
+		if endpointPath(endpoint) == EndpointAuth {
+			apiErr.err = ErrAuthFailure
+		} else {
+			apiErr.err = ErrTokenExpired
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,100 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		codes    []int
+		endpoint string
+		status   int
+		want     error
+	}{
+		{"success", nil, EndpointLikeIDs, http.StatusOK, nil},
+		{"no content", nil, EndpointText, http.StatusNoContent, nil},
+		{"default code", nil, EndpointLikeIDs, http.StatusForbidden, ErrTokenExpired},
+		{"default code on auth", nil, EndpointAuth, http.StatusForbidden, ErrAuthFailure},
+		{"other status", nil, EndpointText, http.StatusUnauthorized, nil},
+		{"configured code", []int{http.StatusUnauthorized}, EndpointText, http.StatusUnauthorized, ErrTokenExpired},
+		{"configured code on auth", []int{http.StatusUnauthorized}, EndpointAuth, http.StatusUnauthorized, ErrAuthFailure},
+		{"default code not configured", []int{http.StatusUnauthorized}, EndpointText, http.StatusForbidden, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var options []ClientOption
+			if test.codes != nil {
+				options = append(options, WithAuthFailureCodes(test.codes...))
+			}
+			client, err := NewClient(options...)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+
+			err = client.classifyResponse(test.endpoint, &response{statusCode: test.status})
+			if test.status < http.StatusBadRequest {
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+				return
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != test.status {
+				t.Fatalf("err = %v, want an *APIError with status %d", err, test.status)
+			}
+			if errors.Unwrap(err) != test.want {
+				t.Errorf("errors.Unwrap(err) = %v, want %v", errors.Unwrap(err), test.want)
+			}
+		})
+	}
+}
+
+func TestValidateTokenAuthFailureCodes(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}, WithAuthFailureCodes(http.StatusUnauthorized))
+
+	valid, err := client.ValidateToken(context.Background(), StubToken())
+	if err != nil || valid {
+		t.Errorf("ValidateToken = %v, %v, want false, nil", valid, err)
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		endpoint string
+		status   int
+		attempts int
+		want     string
+	}{
+		{EndpointText, http.StatusBadRequest, 1, "bad request: body"},
+		{EndpointLikeIDs, http.StatusForbidden, 1, ErrTokenExpired.Error()},
+		{EndpointAuth, http.StatusForbidden, 1, ErrAuthFailure.Error()},
+		{EndpointLikeIDs, http.StatusNotFound, 1, "endpoint not found"},
+		{EndpointText, http.StatusTooManyRequests, 1, "usage limit exceeded: body"},
+		{EndpointText, http.StatusInternalServerError, 3, "api is temporarily not available (after 3 attempts, last status 500)"},
+		{EndpointLikeIDs + "?traits=Age", http.StatusBadGateway, 1, "unexpected status 502 from " + EndpointLikeIDs + ": body"},
+	}
+	for _, test := range tests {
+		err := client.classifyResponse(test.endpoint, &response{statusCode: test.status, body: []byte("body"), attempts: test.attempts})
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s %d: err = %v, want %q", test.endpoint, test.status, err, test.want)
+		}
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusNoContent, http.StatusNotModified} {
+		if err := client.classifyResponse(EndpointText, &response{statusCode: status}); err != nil {
+			t.Errorf("status %d: err = %v, want nil", status, err)
+		}
+	}
+}
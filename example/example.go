@@ -6,8 +6,12 @@ import (
 	ams "github.com/crossi36/applymagicsauce"
 )
 
+// customerID is a placeholder, replace it and "YOUR_API_KEY" with your credentials. Customer IDs are
+// positive; Auth rejects 0 with ErrInvalidCredentials without asking the API.
+const customerID = 12345
+
 func main() {
-	token, err := ams.Auth(0, "YOUR_API_KEY")
+	token, err := ams.Auth(customerID, "YOUR_API_KEY")
 	if err != nil {
 		log.Fatalf("could not get authentication token: %v", err)
 	}
@@ -17,7 +21,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("could not predict text: %v", err)
 	}
-	log.Printf("%#v\n", textPrediction)
+	log.Printf("%v\n", textPrediction)
 
 	ids := []string{"5845317146", "6460713406", "22404294985", "35312278675", "105930651606", "171605907303", "199592894970", "274598553922", "340368556015", "100270610030980"}
 
@@ -26,5 +30,5 @@ func main() {
 	if err != nil {
 		log.Fatalf("could not predict likes: %v", err)
 	}
-	log.Printf("%#v\n", likePredictions)
+	log.Printf("%v\n", likePredictions)
 }
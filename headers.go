@@ -0,0 +1,95 @@
+package applymagicsauce
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WithContentType overrides the Content-Type header sent to endpoint (e.g. EndpointText), for example to
+// use a versioned media type. By default the text endpoint gets "text/plain" and all other endpoints
+// get "application/json".
+func WithContentType(endpoint string, contentType string) ClientOption {
+	return func(c *Client) error {
+		if contentType == "" {
+			return fmt.Errorf("content type must not be empty")
+		}
+		if c.contentTypes == nil {
+			c.contentTypes = make(map[string]string)
+		}
+		c.contentTypes[endpoint] = contentType
+		return nil
+	}
+}
+
+// WithAccept overrides the Accept header sent with every request. The default is "application/json".
+func WithAccept(accept string) ClientOption {
+	return func(c *Client) error {
+		if accept == "" {
+			return fmt.Errorf("accept must not be empty")
+		}
+		c.accept = accept
+		return nil
+	}
+}
+
+// WithAcceptLanguage sets the Accept-Language header of the requests to the text endpoint, e.g. "de"
+// or "fr-CH, fr;q=0.9". The API does not document a language hint at the moment and ignores the
+// header, so this is forward-looking: it lets non-English texts be analyzed with the right model once
+// the API picks the header up. See WithLanguageFilter to keep texts in other languages from being sent.
+func WithAcceptLanguage(language string) ClientOption {
+	return func(c *Client) error {
+		if language == "" {
+			return fmt.Errorf("accept language must not be empty")
+		}
+		c.acceptLanguage = language
+		return nil
+	}
+}
+
+// WithRequestModifier sets a function that is called with every request right before it is sent,
+// after the Client has set all of its headers. It may inspect and change the request, e.g. to sign it
+// or to add headers for routing. If modify returns an error, the request is not sent and the call
+// fails with that error without being retried.
+func WithRequestModifier(modify func(*http.Request) error) ClientOption {
+	return func(c *Client) error {
+		if modify == nil {
+			return fmt.Errorf("request modifier must not be nil")
+		}
+		c.modifyRequest = modify
+		return nil
+	}
+}
+
+// requestModifierError wraps an error of the function set with WithRequestModifier.
+type requestModifierError struct {
+	err error
+}
+
+func (e *requestModifierError) Error() string {
+	return fmt.Sprintf("could not modify request: %v", e.err)
+}
+
+func (e *requestModifierError) Unwrap() error {
+	return e.err
+}
+
+func (c *Client) contentType(endpoint string) string {
+	path := endpointPath(endpoint)
+	if contentType, ok := c.contentTypes[path]; ok {
+		return contentType
+	}
+	if path == EndpointText {
+		// The text endpoint takes the plain text as body, not JSON.
+		return "text/plain"
+	}
+	return "application/json"
+}
+
+// endpointPath strips the query from endpoint.
+func endpointPath(endpoint string) string {
+	if i := strings.IndexByte(endpoint, '?'); i >= 0 {
+		return endpoint[:i]
+	}
+	return endpoint
+}
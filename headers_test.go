@@ -0,0 +1,161 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// headerRecorder is a handler that keeps the headers of the last request per path and answers like
+// the API.
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers map[string]http.Header
+}
+
+func (h *headerRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	if h.headers == nil {
+		h.headers = make(map[string]http.Header)
+	}
+	h.headers[r.URL.Path] = r.Header.Clone()
+	h.mu.Unlock()
+
+	if r.URL.Path == EndpointAuth {
+		w.Write([]byte(`{"token":"t","customer_id":1}`))
+		return
+	}
+	w.Write([]byte(`{"input_used":1}`))
+}
+
+func (h *headerRecorder) get(path, key string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.headers[path].Get(key)
+}
+
+// callEndpoints sends a request to each of the endpoints of the API.
+func callEndpoints(t *testing.T, client *Client) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := client.Auth(ctx, 1, "key"); err != nil {
+		t.Fatalf("Auth: %v", err)
+	}
+	if _, err := client.PredictText(ctx, "text", MinimalBigFiveOptions(SourceOther), StubToken()); err != nil {
+		t.Fatalf("PredictText: %v", err)
+	}
+	if _, err := client.PredictLikeIDs(ctx, []string{"1"}, nil, StubToken()); err != nil {
+		t.Fatalf("PredictLikeIDs: %v", err)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		want    map[string]string
+		accept  string
+	}{
+		{"defaults", nil, map[string]string{
+			EndpointAuth:    "application/json",
+			EndpointText:    "text/plain",
+			EndpointLikeIDs: "application/json",
+		}, "application/json"},
+		{"overrides", []ClientOption{
+			WithContentType(EndpointText, "text/plain; charset=utf-8"),
+			WithContentType(EndpointLikeIDs, "application/vnd.ams.v2+json"),
+			WithAccept("application/vnd.ams.v2+json"),
+		}, map[string]string{
+			EndpointAuth:    "application/json",
+			EndpointText:    "text/plain; charset=utf-8",
+			EndpointLikeIDs: "application/vnd.ams.v2+json",
+		}, "application/vnd.ams.v2+json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &headerRecorder{}
+			callEndpoints(t, newTestClient(t, recorder.ServeHTTP, test.options...))
+			for endpoint, want := range test.want {
+				if got := recorder.get(endpoint, "Content-Type"); got != want {
+					t.Errorf("Content-Type of %s = %q, want %q", endpoint, got, want)
+				}
+				if got := recorder.get(endpoint, "Accept"); got != test.accept {
+					t.Errorf("Accept of %s = %q, want %q", endpoint, got, test.accept)
+				}
+			}
+		})
+	}
+
+	if _, err := NewClient(WithContentType(EndpointText, "")); err == nil {
+		t.Error("WithContentType accepted an empty content type")
+	}
+}
+
+func TestRequestModifier(t *testing.T) {
+	recorder := &headerRecorder{}
+	var seenContentType string
+	client := newTestClient(t, recorder.ServeHTTP, WithRequestModifier(func(r *http.Request) error {
+		// The headers of the Client are already set.
+		seenContentType = r.Header.Get("Content-Type")
+		r.Header.Set("X-Tenant", "blue")
+		return nil
+	}))
+	callEndpoints(t, client)
+	for _, endpoint := range []string{EndpointAuth, EndpointText, EndpointLikeIDs} {
+		if got := recorder.get(endpoint, "X-Tenant"); got != "blue" {
+			t.Errorf("X-Tenant of %s = %q, want the header of the modifier", endpoint, got)
+		}
+	}
+	if seenContentType != "application/json" {
+		t.Errorf("modifier saw Content-Type %q, want the one set by the Client", seenContentType)
+	}
+
+	errSigning := errors.New("signing key unavailable")
+	var calls int
+	client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request sent to %s although the modifier failed", r.URL.Path)
+	}, WithRetry(3, 0), WithRequestModifier(func(r *http.Request) error {
+		calls++
+		return errSigning
+	}))
+	_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+	if !errors.Is(err, errSigning) {
+		t.Errorf("err = %v, want the error of the modifier", err)
+	}
+	if calls != 1 {
+		t.Errorf("modifier called %d times, want once without retries", calls)
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		want    string
+	}{
+		{"default", nil, ""},
+		{"set", []ClientOption{WithAcceptLanguage("fr-CH, fr;q=0.9")}, "fr-CH, fr;q=0.9"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &headerRecorder{}
+			callEndpoints(t, newTestClient(t, recorder.ServeHTTP, test.options...))
+			if got := recorder.get(EndpointText, "Accept-Language"); got != test.want {
+				t.Errorf("Accept-Language of %s = %q, want %q", EndpointText, got, test.want)
+			}
+			// Only texts are analyzed in a language.
+			for _, endpoint := range []string{EndpointAuth, EndpointLikeIDs} {
+				if got := recorder.get(endpoint, "Accept-Language"); got != "" {
+					t.Errorf("Accept-Language of %s = %q, want none", endpoint, got)
+				}
+			}
+		})
+	}
+
+	if _, err := NewClient(WithAcceptLanguage("")); err == nil {
+		t.Error("WithAcceptLanguage accepted an empty language")
+	}
+}
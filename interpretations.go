@@ -0,0 +1,103 @@
+package applymagicsauce
+
+import "strings"
+
+// GenderInterpretation is the interpretation of TraitFemale.
+type GenderInterpretation struct {
+	// Label is the predicted gender, e.g. "Female".
+	Label string
+	// Confidence is the probability of Label between 0 and 1, or 0 if it is unknown.
+	Confidence float64
+}
+
+// AgeInterpretation is the interpretation of TraitAge.
+type AgeInterpretation struct {
+	// Label is the predicted age group, e.g. "25-34".
+	Label string
+	// Confidence is the probability of Label between 0 and 1, or 0 if it is unknown.
+	Confidence float64
+}
+
+// PoliticsInterpretation is the interpretation of TraitPolitics.
+type PoliticsInterpretation struct {
+	// Label is the predicted political orientation, e.g. "Liberal".
+	Label string
+	// Confidence is the probability of Label between 0 and 1, or 0 if it is unknown.
+	Confidence float64
+}
+
+// Gender returns the interpretation of TraitFemale and whether there is one that could be parsed. If
+// the interpretation has no probability, Confidence is derived from the predicted value of TraitFemale.
+func (p Predictions) Gender() (gender GenderInterpretation, ok bool) {
+	gender.Label, gender.Confidence, ok = p.labeledInterpretation(TraitFemale)
+	if !ok {
+		return gender, false
+	}
+	if female, predicted := p.Value(TraitFemale); predicted && gender.Confidence == 0 {
+		if strings.EqualFold(gender.Label, "female") {
+			gender.Confidence = female
+		} else {
+			gender.Confidence = 1 - female
+		}
+	}
+	return gender, true
+}
+
+// Age returns the interpretation of TraitAge and whether there is one that could be parsed.
+func (p Predictions) Age() (age AgeInterpretation, ok bool) {
+	age.Label, age.Confidence, ok = p.labeledInterpretation(TraitAge)
+	return age, ok
+}
+
+// Politics returns the interpretation of TraitPolitics and whether there is one that could be parsed.
+// If the interpretation has no probability, Confidence is taken from the related prediction of the
+// label (e.g. "Politics_Liberal") if there is one.
+func (p Predictions) Politics() (politics PoliticsInterpretation, ok bool) {
+	politics.Label, politics.Confidence, ok = p.labeledInterpretation(TraitPolitics)
+	if !ok {
+		return politics, false
+	}
+	if value, predicted := p.Value(TraitPolitics + "_" + politics.Label); predicted && politics.Confidence == 0 {
+		politics.Confidence = value
+	}
+	return politics, true
+}
+
+// labelKeys and confidenceKeys are the keys that may hold the label and the probability in a
+// structured interpretation.
+var (
+	labelKeys      = []string{"label", "value", "name"}
+	confidenceKeys = []string{"probability", "confidence", "score"}
+)
+
+// labeledInterpretation parses the interpretation of trait. It is either a plain label or an object
+// with a label and a probability.
+func (p Predictions) labeledInterpretation(trait string) (label string, confidence float64, ok bool) {
+	value, found := p.Interpretation(trait)
+	if !found {
+		return "", 0, false
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, 0, value != ""
+	case map[string]interface{}:
+		for _, key := range labelKeys {
+			if l, isString := value[key].(string); isString && l != "" {
+				label = l
+				break
+			}
+		}
+		if label == "" {
+			return "", 0, false
+		}
+		for _, key := range confidenceKeys {
+			if c, isNumber := value[key].(float64); isNumber {
+				confidence = c
+				break
+			}
+		}
+		return label, confidence, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,66 @@
+package applymagicsauce
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDemographicInterpretations(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       string
+		wantGender     GenderInterpretation
+		wantAge        AgeInterpretation
+		wantPolitics   PoliticsInterpretation
+		wantInterprets bool
+	}{
+		{
+			name: "labels with probabilities",
+			response: `{"predictions":[{"trait":"Female","value":0.9}],"interpretations":[
+				{"trait":"Female","value":{"label":"Female","probability":0.9}},
+				{"trait":"Age","value":{"label":"25-34","probability":0.41}},
+				{"trait":"Politics","value":{"name":"Liberal","score":0.62}}]}`,
+			wantGender:     GenderInterpretation{"Female", 0.9},
+			wantAge:        AgeInterpretation{"25-34", 0.41},
+			wantPolitics:   PoliticsInterpretation{"Liberal", 0.62},
+			wantInterprets: true,
+		},
+		{
+			name: "plain labels",
+			response: `{"predictions":[{"trait":"Female","value":0.2},{"trait":"Politics_Conservative","value":0.7}],"interpretations":[
+				{"trait":"Female","value":"Male"},
+				{"trait":"Age","value":"18-24"},
+				{"trait":"Politics","value":"Conservative"}]}`,
+			wantGender:     GenderInterpretation{"Male", 0.8},
+			wantAge:        AgeInterpretation{"18-24", 0},
+			wantPolitics:   PoliticsInterpretation{"Conservative", 0.7},
+			wantInterprets: true,
+		},
+		{
+			name:     "unparseable",
+			response: `{"interpretations":[{"trait":"Female","value":1},{"trait":"Age","value":{"probability":0.5}},{"trait":"Politics","value":""}]}`,
+		},
+		{
+			name:     "missing",
+			response: `{"predictions":[{"trait":"Female","value":0.9}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var p Predictions
+			if err := json.Unmarshal([]byte(test.response), &p); err != nil {
+				t.Fatal(err)
+			}
+			if gender, ok := p.Gender(); gender != test.wantGender || ok != test.wantInterprets {
+				t.Errorf("Gender() = %+v, %v, want %+v, %v", gender, ok, test.wantGender, test.wantInterprets)
+			}
+			if age, ok := p.Age(); age != test.wantAge || ok != test.wantInterprets {
+				t.Errorf("Age() = %+v, %v, want %+v, %v", age, ok, test.wantAge, test.wantInterprets)
+			}
+			if politics, ok := p.Politics(); politics != test.wantPolitics || ok != test.wantInterprets {
+				t.Errorf("Politics() = %+v, %v, want %+v, %v", politics, ok, test.wantPolitics, test.wantInterprets)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package applymagicsauce
+
+import "iter"
+
+// Predictions2 returns an iterator over the predictions, yielding the trait and its entry in the order
+// of the Predictions field.
+func (p Predictions) Predictions2() iter.Seq2[string, PredictionEntry] {
+	return func(yield func(string, PredictionEntry) bool) {
+		for _, prediction := range p.Predictions {
+			if !yield(prediction.Trait, prediction) {
+				return
+			}
+		}
+	}
+}
+
+// Interpretations2 returns an iterator over the interpretations, yielding the trait and its entry in
+// the order of the Interpretations field.
+func (p Predictions) Interpretations2() iter.Seq2[string, InterpretationEntry] {
+	return func(yield func(string, InterpretationEntry) bool) {
+		for _, interpretation := range p.Interpretations {
+			if !yield(interpretation.Trait, interpretation) {
+				return
+			}
+		}
+	}
+}
+
+// Contributors2 returns an iterator over the contributors, yielding the trait and its entry in the
+// order of the Contributors field.
+func (p Predictions) Contributors2() iter.Seq2[string, ContributorEntry] {
+	return func(yield func(string, ContributorEntry) bool) {
+		for _, contributor := range p.Contributors {
+			if !yield(contributor.Trait, contributor) {
+				return
+			}
+		}
+	}
+}
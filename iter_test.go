@@ -0,0 +1,54 @@
+package applymagicsauce
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	p := Predictions{
+		Predictions:     []PredictionEntry{{TraitOpenness, 0.1}, {TraitAge, 30}, {TraitFemale, 0.7}},
+		Interpretations: []InterpretationEntry{{TraitAge, "30"}, {TraitOpenness, "low"}},
+		Contributors:    []ContributorEntry{{TraitOpenness, []string{"1"}, []string{"2"}}},
+	}
+
+	var predictions []PredictionEntry
+	for trait, prediction := range p.Predictions2() {
+		if trait != prediction.Trait {
+			t.Errorf("yielded %s for the prediction of %s", trait, prediction.Trait)
+		}
+		predictions = append(predictions, prediction)
+	}
+	if !reflect.DeepEqual(predictions, p.Predictions) {
+		t.Errorf("Predictions2 yielded %v, want %v", predictions, p.Predictions)
+	}
+
+	var interpretations []InterpretationEntry
+	for _, interpretation := range p.Interpretations2() {
+		interpretations = append(interpretations, interpretation)
+	}
+	if !reflect.DeepEqual(interpretations, p.Interpretations) {
+		t.Errorf("Interpretations2 yielded %v, want %v", interpretations, p.Interpretations)
+	}
+
+	var contributors []ContributorEntry
+	for _, contributor := range p.Contributors2() {
+		contributors = append(contributors, contributor)
+	}
+	if !reflect.DeepEqual(contributors, p.Contributors) {
+		t.Errorf("Contributors2 yielded %v, want %v", contributors, p.Contributors)
+	}
+
+	// Breaking out of the loop stops the iteration.
+	var visited int
+	for range p.Predictions2() {
+		visited++
+		break
+	}
+	if visited != 1 {
+		t.Errorf("visited %d predictions after break, want 1", visited)
+	}
+	for range (Predictions{}).Contributors2() {
+		t.Error("iterator of empty predictions yielded an entry")
+	}
+}
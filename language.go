@@ -0,0 +1,116 @@
+package applymagicsauce
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrUnsupportedLanguage is returned by PredictText if the language of the text is not allowed by
+// WithLanguageFilter.
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// LanguageDetector detects the language of a text. Detect returns the ISO 639-1 code of the language
+// (e.g. "en") or an empty string if the language could not be detected.
+type LanguageDetector interface {
+	Detect(text string) string
+}
+
+// LanguageDetectorFunc is an adapter to use an ordinary function as LanguageDetector.
+type LanguageDetectorFunc func(text string) string
+
+// Detect implements LanguageDetector.
+func (f LanguageDetectorFunc) Detect(text string) string {
+	return f(text)
+}
+
+// WithLanguageFilter makes PredictText detect the language of the text before sending it to the API.
+// Texts in any other than the allowed languages (ISO 639-1 codes, e.g. "en") are not sent and
+// ErrUnsupportedLanguage is returned instead. Texts whose language can not be detected are sent as
+// usual.
+//
+// The default detector is a small built-in one that recognizes common words of a handful of European
+// languages. Use WithLanguageDetector to plug in a better one.
+func WithLanguageFilter(allowed ...string) ClientOption {
+	return func(c *Client) error {
+		c.allowedLanguages = make(map[string]bool, len(allowed))
+		for _, language := range allowed {
+			c.allowedLanguages[strings.ToLower(language)] = true
+		}
+		if c.languageDetector == nil {
+			c.languageDetector = stopwordDetector{}
+		}
+		return nil
+	}
+}
+
+// WithLanguageDetector sets the LanguageDetector used by WithLanguageFilter.
+func WithLanguageDetector(detector LanguageDetector) ClientOption {
+	return func(c *Client) error {
+		if detector == nil {
+			return fmt.Errorf("language detector must not be nil")
+		}
+		c.languageDetector = detector
+		return nil
+	}
+}
+
+func (c *Client) checkLanguage(text string) error {
+	if len(c.allowedLanguages) == 0 {
+		return nil
+	}
+
+	language := strings.ToLower(c.languageDetector.Detect(text))
+	if language == "" || c.allowedLanguages[language] {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+}
+
+// minDetectionWords is the number of known words the stopwordDetector needs to make a decision.
+const minDetectionWords = 3
+
+// stopwords contains some of the most frequent words for each language the stopwordDetector knows.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "was", "of", "to", "in", "that", "it", "with", "for", "you", "this", "have", "not", "be", "on"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "sie", "es", "mit", "ein", "eine", "zu", "auf", "den", "von", "sich", "auch"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "des", "que", "pas", "je", "vous", "dans", "pour", "sur", "avec", "ce", "qui"},
+	"es": {"el", "los", "las", "y", "es", "una", "que", "del", "por", "con", "para", "como", "pero", "su", "muy", "yo", "lo", "se"},
+	"it": {"il", "di", "che", "è", "gli", "per", "una", "non", "sono", "con", "della", "ma", "come", "anche", "questo", "io", "mi", "ho"},
+	"nl": {"de", "het", "een", "en", "van", "ik", "niet", "dat", "zijn", "met", "voor", "op", "maar", "ook", "je", "wat", "er", "heb"},
+	"pt": {"o", "os", "um", "uma", "não", "que", "do", "da", "em", "para", "com", "mas", "como", "ao", "eu", "você", "isso", "muito"},
+}
+
+// stopwordDetector is a lightweight LanguageDetector that counts the occurrences of frequent words.
+type stopwordDetector struct{}
+
+func (stopwordDetector) Detect(text string) string {
+	counts := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		for language, list := range stopwords {
+			for _, stopword := range list {
+				if word == stopword {
+					counts[language]++
+				}
+			}
+		}
+	}
+
+	best, bestCount, tie := "", 0, false
+	for language, count := range counts {
+		switch {
+		case count > bestCount:
+			best, bestCount, tie = language, count, false
+		case count == bestCount:
+			tie = true
+		}
+	}
+	if bestCount < minDetectionWords || tie {
+		return ""
+	}
+	return best
+}
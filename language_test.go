@@ -0,0 +1,70 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStopwordDetector(t *testing.T) {
+	tests := map[string]string{
+		"The weather is nice and it is warm in the city.":             "en",
+		"Das Wetter ist schön und es ist warm in der Stadt.":          "de",
+		"Le temps est beau et il fait chaud dans la ville.":           "fr",
+		"El tiempo es bueno y hace calor en la ciudad, pero no mucho": "es",
+		"Ciao":       "",
+		"12345 !!!":  "",
+		"the and le": "",
+	}
+	for text, want := range tests {
+		if got := (stopwordDetector{}).Detect(text); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLanguageFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []ClientOption
+		text     string
+		wantSent bool
+	}{
+		{"allowed", []ClientOption{WithLanguageFilter("EN")}, "This is a text that is written in English.", true},
+		{"not allowed", []ClientOption{WithLanguageFilter("en")}, "Das ist ein Text, der nicht auf Englisch ist.", false},
+		{"undetected", []ClientOption{WithLanguageFilter("en")}, "Hmm.", true},
+		{"no filter", nil, "Das ist ein Text, der nicht auf Englisch ist.", true},
+		{"custom detector", []ClientOption{
+			WithLanguageDetector(LanguageDetectorFunc(func(string) string { return "fi" })),
+			WithLanguageFilter("en"),
+		}, "This is a text that is written in English.", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent := false
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				sent = true
+				w.Write([]byte(`{"input_used":1}`))
+			}, test.options...)
+
+			_, err := client.PredictText(context.Background(), test.text, MinimalBigFiveOptions(SourceOther), StubToken())
+			if sent != test.wantSent {
+				t.Errorf("sent = %t, want %t", sent, test.wantSent)
+			}
+			if !test.wantSent && !errors.Is(err, ErrUnsupportedLanguage) {
+				t.Errorf("err = %v, want ErrUnsupportedLanguage", err)
+			}
+			if test.wantSent && err != nil {
+				t.Errorf("err = %v", err)
+			}
+		})
+	}
+}
+
+func TestNilLanguageDetector(t *testing.T) {
+	if _, err := NewClient(WithLanguageFilter("en"), WithLanguageDetector(nil)); err == nil {
+		t.Error("NewClient accepted a nil language detector")
+	}
+}
@@ -0,0 +1,78 @@
+package applymagicsauce
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NormalizeLikeIDs returns ids with surrounding whitespace trimmed, empty IDs removed and duplicates
+// removed, keeping the order of the first occurrence of every ID. Duplicates do not improve the
+// predictions, but may count against WithMaxLikeIDs. ids is not modified.
+func NormalizeLikeIDs(ids []string) []string {
+	normalized := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		normalized = append(normalized, id)
+	}
+	return normalized
+}
+
+// LoadLikeIDsFromReader reads Like IDs from r, e.g. a file, with one ID per line. The lines may also
+// form a CSV file with a single column, so quoted IDs are supported. Everything after a # outside of
+// quotes is a comment, so blank lines, comment lines and comments after an ID are skipped. The IDs
+// are normalized with NormalizeLikeIDs.
+func LoadLikeIDsFromReader(r io.Reader) ([]string, error) {
+	// Comments are removed before parsing, as encoding/csv only knows comments at the very beginning
+	// of a line. Every line is kept, so that the line numbers of errors stay right.
+	var cleaned strings.Builder
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		cleaned.WriteString(strings.TrimSpace(stripComment(scanner.Text())))
+		cleaned.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read like ids: %w", err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(cleaned.String()))
+	reader.FieldsPerRecord = -1
+
+	var ids []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read like ids: %w", err)
+		}
+		if len(record) > 1 {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("could not read like ids: line %d has %d columns, expected one", line, len(record))
+		}
+		ids = append(ids, record[0])
+	}
+	return NormalizeLikeIDs(ids), nil
+}
+
+// stripComment returns line without a comment starting with a # outside of quotes.
+func stripComment(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '#' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
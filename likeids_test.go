@@ -0,0 +1,54 @@
+package applymagicsauce
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLikeIDsFromReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{"one per line", "1\n2\n3\n", []string{"1", "2", "3"}, ""},
+		{"blank lines and whitespace", "\n  1  \n\n\t2\n", []string{"1", "2"}, ""},
+		{"comment lines", "# ids\n1\n  # a, b\n2", []string{"1", "2"}, ""},
+		{"trailing comments", "5 # note\n6# another, with comma\n", []string{"5", "6"}, ""},
+		{"quoted", "\"7\"\n\"8 # not a comment\"\n", []string{"7", "8 # not a comment"}, ""},
+		{"duplicates", "1\n2\n1\n", []string{"1", "2"}, ""},
+		{"several columns", "1\n2,3\n", nil, "line 2 has 2 columns"},
+		{"several columns after a comment line", "# comment\n\n1,2\n", nil, "line 3 has 2 columns"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := LoadLikeIDsFromReader(strings.NewReader(test.input))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("err = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadLikeIDsFromReader: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLikeIDs(t *testing.T) {
+	ids := []string{" 1", "2", "", "1", " ", "3 "}
+	want := []string{"1", "2", "3"}
+	if got := NormalizeLikeIDs(ids); !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeLikeIDs = %q, want %q", got, want)
+	}
+	if ids[0] != " 1" {
+		t.Error("NormalizeLikeIDs modified its argument")
+	}
+}
@@ -0,0 +1,95 @@
+package applymagicsauce
+
+// WeightedPrediction is a set of Predictions with a weight for MergePredictions.
+type WeightedPrediction struct {
+	Predictions
+	Weight float64
+}
+
+// MergePredictions combines the predictions of several sources, e.g. several documents or Like sets of
+// the same person. The value of every trait is the weighted mean over the sources that predicted it,
+// with the weights normalized among those sources. Traits whose sources have a total weight of zero
+// are omitted.
+//
+// Interpretations and contributors can not be combined, so for every trait they are taken from the
+// source with the highest weight that has them. InputUsed and Submitted are summed up. Stale,
+// Partial and ContributorsDropped are set if they are set for any of the sources. The Source is
+// SourceStale if any of the sources is stale, the Source of all sources if they have the same one
+// (e.g. SourceDryRun for dry runs) and SourceLive otherwise.
+func MergePredictions(weighted ...WeightedPrediction) (merged Predictions) {
+	type sum struct {
+		value, weight float64
+	}
+	sums := make(map[string]*sum)
+	var order []string
+
+	sameSource := len(weighted) > 0
+	for _, w := range weighted {
+		merged.InputUsed += w.InputUsed
+		merged.Submitted += w.Submitted
+		merged.Stale = merged.Stale || w.Stale
+		merged.Partial = merged.Partial || w.Partial
+		merged.ContributorsDropped = merged.ContributorsDropped || w.ContributorsDropped
+		if w.Source != weighted[0].Source {
+			sameSource = false
+		}
+
+		for _, prediction := range w.Predictions.Predictions {
+			s, ok := sums[prediction.Trait]
+			if !ok {
+				s = new(sum)
+				sums[prediction.Trait] = s
+				order = append(order, prediction.Trait)
+			}
+			s.value += prediction.Value * w.Weight
+			s.weight += w.Weight
+		}
+	}
+
+	switch {
+	case merged.Stale:
+		merged.Source = SourceStale
+	case sameSource:
+		merged.Source = weighted[0].Source
+	}
+
+	for _, trait := range order {
+		if s := sums[trait]; s.weight != 0 {
+			merged.Predictions = append(merged.Predictions, PredictionEntry{
+				Trait: trait,
+				Value: s.value / s.weight,
+			})
+		}
+	}
+
+	interpretations := make(map[string]int)
+	contributors := make(map[string]int)
+	for i, w := range weighted {
+		for _, interpretation := range w.Interpretations {
+			if j, ok := interpretations[interpretation.Trait]; !ok || w.Weight > weighted[j].Weight {
+				interpretations[interpretation.Trait] = i
+			}
+		}
+		for _, contributor := range w.Contributors {
+			if j, ok := contributors[contributor.Trait]; !ok || w.Weight > weighted[j].Weight {
+				contributors[contributor.Trait] = i
+			}
+		}
+	}
+	for i, w := range weighted {
+		for _, interpretation := range w.Interpretations {
+			if interpretations[interpretation.Trait] == i {
+				merged.Interpretations = append(merged.Interpretations, interpretation)
+				delete(interpretations, interpretation.Trait)
+			}
+		}
+		for _, contributor := range w.Contributors {
+			if contributors[contributor.Trait] == i {
+				merged.Contributors = append(merged.Contributors, contributor)
+				delete(contributors, contributor.Trait)
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,107 @@
+package applymagicsauce
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergePredictions(t *testing.T) {
+	openness := func(value float64) []PredictionEntry {
+		return []PredictionEntry{{Trait: TraitOpenness, Value: value}}
+	}
+	tests := []struct {
+		name     string
+		weighted []WeightedPrediction
+		want     Predictions
+	}{
+		{
+			name: "weighted mean",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{InputUsed: 1, Predictions: openness(0.25)}, Weight: 1},
+				{Predictions: Predictions{InputUsed: 3, Predictions: openness(0.75)}, Weight: 3},
+			},
+			want: Predictions{InputUsed: 4, Predictions: openness(0.625)},
+		},
+		{
+			// Age only counts the sources that have it, so its weights are normalized among those two.
+			name: "uneven coverage",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Predictions: []PredictionEntry{{TraitOpenness, 0.2}, {TraitAge, 20}}}, Weight: 1},
+				{Predictions: Predictions{Predictions: openness(0.8)}, Weight: 2},
+				{Predictions: Predictions{Predictions: []PredictionEntry{{TraitAge, 40}}}, Weight: 3},
+			},
+			want: Predictions{Predictions: []PredictionEntry{{TraitOpenness, 0.6}, {TraitAge, 35}}},
+		},
+		{
+			name: "zero weight",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Predictions: openness(0.2)}, Weight: 0},
+			},
+			want: Predictions{},
+		},
+		{
+			name: "partial",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Partial: true}, Weight: 1},
+				{Predictions: Predictions{}, Weight: 1},
+			},
+			want: Predictions{Partial: true},
+		},
+		{
+			name: "contributors dropped",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{}, Weight: 1},
+				{Predictions: Predictions{ContributorsDropped: true}, Weight: 1},
+			},
+			want: Predictions{ContributorsDropped: true},
+		},
+		{
+			name: "stale",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Source: SourceCache}, Weight: 1},
+				{Predictions: Predictions{Source: SourceStale, Stale: true}, Weight: 1},
+			},
+			want: Predictions{Source: SourceStale, Stale: true},
+		},
+		{
+			name: "cached",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Source: SourceCache}, Weight: 1},
+				{Predictions: Predictions{Source: SourceCache}, Weight: 1},
+			},
+			want: Predictions{Source: SourceCache},
+		},
+		{
+			name: "dry run",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Source: SourceDryRun}, Weight: 1},
+				{Predictions: Predictions{Source: SourceDryRun}, Weight: 1},
+			},
+			want: Predictions{Source: SourceDryRun},
+		},
+		{
+			name: "mixed sources",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Source: SourceCache}, Weight: 1},
+				{Predictions: Predictions{Source: SourceDryRun}, Weight: 1},
+			},
+			want: Predictions{Source: SourceLive},
+		},
+		{
+			name: "contributors of the heaviest source",
+			weighted: []WeightedPrediction{
+				{Predictions: Predictions{Contributors: []ContributorEntry{{Trait: TraitOpenness, Positive: []string{"1"}}}}, Weight: 1},
+				{Predictions: Predictions{Contributors: []ContributorEntry{{Trait: TraitOpenness, Positive: []string{"2"}}}}, Weight: 2},
+			},
+			want: Predictions{Contributors: []ContributorEntry{{Trait: TraitOpenness, Positive: []string{"2"}}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := MergePredictions(test.weighted...); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("MergePredictions = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// ResultMeta describes how a result was obtained. See Client.PredictTextWithMeta.
+type ResultMeta struct {
+	// Duration is the time the whole call took, including retries and token renewal.
+	Duration time.Duration
+	// Attempts is the number of HTTP requests sent for the call. It is zero for results from the
+	// cache and greater than one if requests were retried.
+	Attempts int
+	// FromCache is set if the result was taken from the cache, including stale results.
+	FromCache bool
+}
+
+type callInfoKey struct{}
+
+// callInfo collects information about a single call while it passes through the Client.
+type callInfo struct {
+	attempts atomic.Int64
+}
+
+func withCallInfo(ctx context.Context) (context.Context, *callInfo) {
+	info := new(callInfo)
+	return context.WithValue(ctx, callInfoKey{}, info), info
+}
+
+func callInfoFrom(ctx context.Context) *callInfo {
+	info, _ := ctx.Value(callInfoKey{}).(*callInfo)
+	return info
+}
+
+func (info *callInfo) meta(start time.Time, predictions Predictions) ResultMeta {
+	return ResultMeta{
+		Duration:  time.Since(start),
+		Attempts:  int(info.attempts.Load()),
+		FromCache: predictions.Source == SourceCache || predictions.Source == SourceStale,
+	}
+}
+
+// PredictTextWithMeta works like PredictText, but additionally returns information about how the
+// result was obtained.
+func (c *Client) PredictTextWithMeta(ctx context.Context, text string, options url.Values, auth *Token) (predictions Predictions, meta ResultMeta, err error) {
+	start := time.Now()
+	ctx, info := withCallInfo(ctx)
+	predictions, err = c.PredictText(ctx, text, options, auth)
+	return predictions, info.meta(start, predictions), err
+}
+
+// PredictLikeIDsWithMeta works like PredictLikeIDs, but additionally returns information about how the
+// result was obtained.
+func (c *Client) PredictLikeIDsWithMeta(ctx context.Context, ids []string, options url.Values, auth *Token) (predictions Predictions, meta ResultMeta, err error) {
+	start := time.Now()
+	ctx, info := withCallInfo(ctx)
+	predictions, err = c.PredictLikeIDs(ctx, ids, options, auth)
+	return predictions, info.meta(start, predictions), err
+}
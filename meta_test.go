@@ -0,0 +1,45 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestResultMeta(t *testing.T) {
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithRetry(3, 0), WithCache(NewMemoryCache()))
+	ctx := context.Background()
+	options := MinimalBigFiveOptions(SourceOther)
+
+	_, meta, err := client.PredictTextWithMeta(ctx, "text", options, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Attempts != 3 || meta.FromCache || meta.Duration <= 0 {
+		t.Errorf("meta of a retried call = %+v, want 3 attempts and a duration", meta)
+	}
+
+	_, meta, err = client.PredictTextWithMeta(ctx, "text", options, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Attempts != 0 || !meta.FromCache {
+		t.Errorf("meta of a cached call = %+v, want no attempts from the cache", meta)
+	}
+
+	_, meta, err = client.PredictLikeIDsWithMeta(ctx, []string{"1"}, nil, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Attempts != 1 || meta.FromCache || meta.Duration <= 0 {
+		t.Errorf("meta of a single request = %+v, want 1 attempt and a duration", meta)
+	}
+}
@@ -0,0 +1,86 @@
+package applymagicsauce
+
+import "math"
+
+// Normalizer maps raw trait values to percentiles (0 to 100) of a reference population.
+type Normalizer interface {
+	// Normalize returns the percentile of value for trait and whether the Normalizer has reference
+	// data for trait.
+	Normalize(trait string, value float64) (percentile float64, ok bool)
+}
+
+// NormalDistribution describes the distribution of a trait in a reference population by its mean and
+// standard deviation.
+type NormalDistribution struct {
+	Mean   float64
+	StdDev float64
+}
+
+// NormalDistributions is a Normalizer based on normally distributed reference data per trait, e.g.
+// statistics published for a population of interest.
+type NormalDistributions map[string]NormalDistribution
+
+// Normalize implements Normalizer.
+func (d NormalDistributions) Normalize(trait string, value float64) (percentile float64, ok bool) {
+	distribution, ok := d[trait]
+	if !ok || distribution.StdDev <= 0 {
+		return 0, false
+	}
+	z := (value - distribution.Mean) / distribution.StdDev
+	return 50 * (1 + math.Erf(z/math.Sqrt2)), true
+}
+
+// unitScaleNormalizer is the built-in Normalizer. It knows the traits the API reports on a scale from
+// 0 to 1 relative to its own reference sample, so the percentile is the value scaled to 0 to 100.
+type unitScaleNormalizer map[string]bool
+
+func (n unitScaleNormalizer) Normalize(trait string, value float64) (percentile float64, ok bool) {
+	if !n[trait] {
+		return 0, false
+	}
+	return math.Max(0, math.Min(100, value*100)), true
+}
+
+// DefaultNormalizer is the Normalizer used by NormalizeScore and Predictions.Normalized. The built-in
+// one covers the Big Five, TraitLifeSatisfaction and TraitIntelligence, which the API already reports
+// relative to the reference sample of the provider, so it only scales them to 0 to 100. Set it to a
+// NormalDistributions with your own reference statistics to compare against another population. It is
+// a global setting, so change it before normalizing any scores.
+//
+// The built-in Normalizer bundles no statistics of its own. Its reference data is the sample the
+// models of the API were trained on, as described in the technical documentation of the provider
+// (https://applymagicsauce.com/documentation_technical.html) for the API at ProductionURL, which
+// reports these traits as the share of that sample scoring lower, from 0 to 1. The reference data is
+// thus versioned with the models: Predictions.ModelVersion tells which one the scores relate to, if the
+// API reports it.
+var DefaultNormalizer Normalizer = unitScaleNormalizer{
+	TraitOpenness:          true,
+	TraitConscientiousness: true,
+	TraitExtraversion:      true,
+	TraitAgreeableness:     true,
+	TraitNeuroticism:       true,
+	TraitLifeSatisfaction:  true,
+	TraitIntelligence:      true,
+}
+
+// NormalizeScore returns the percentile (0 to 100) of the raw value of trait according to
+// DefaultNormalizer. If there is no reference data for trait, NaN is returned.
+func NormalizeScore(trait string, value float64) float64 {
+	percentile, ok := DefaultNormalizer.Normalize(trait, value)
+	if !ok {
+		return math.NaN()
+	}
+	return percentile
+}
+
+// Normalized returns the percentiles of all predicted traits DefaultNormalizer has reference data for,
+// keyed by trait. See NormalizeScore.
+func (p Predictions) Normalized() map[string]float64 {
+	normalized := make(map[string]float64)
+	for _, prediction := range p.Predictions {
+		if percentile, ok := DefaultNormalizer.Normalize(prediction.Trait, prediction.Value); ok {
+			normalized[prediction.Trait] = percentile
+		}
+	}
+	return normalized
+}
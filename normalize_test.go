@@ -0,0 +1,62 @@
+package applymagicsauce
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeScore(t *testing.T) {
+	tests := []struct {
+		trait string
+		value float64
+		want  float64
+	}{
+		{TraitOpenness, 0.5, 50},
+		{TraitNeuroticism, 0.025, 2.5},
+		{TraitIntelligence, 0.975, 97.5},
+		{TraitExtraversion, 1.2, 100},
+		{TraitAgreeableness, -0.1, 0},
+		{"unknown", 0.5, math.NaN()},
+	}
+	for _, test := range tests {
+		got := NormalizeScore(test.trait, test.value)
+		if math.Abs(got-test.want) > 1e-9 || math.IsNaN(got) != math.IsNaN(test.want) {
+			t.Errorf("NormalizeScore(%s, %g) = %g, want %g", test.trait, test.value, got, test.want)
+		}
+	}
+}
+
+func TestNormalDistributions(t *testing.T) {
+	distributions := NormalDistributions{
+		TraitOpenness:    {Mean: 3.5, StdDev: 0.5},
+		TraitNeuroticism: {Mean: 1, StdDev: 0},
+	}
+	// The percentiles of the standard normal distribution at -1.96, -1, 0, 1 and 1.96.
+	points := map[float64]float64{2.52: 2.4998, 3: 15.8655, 3.5: 50, 4: 84.1345, 4.48: 97.5002}
+	for value, want := range points {
+		got, ok := distributions.Normalize(TraitOpenness, value)
+		if !ok || math.Abs(got-want) > 1e-4 {
+			t.Errorf("Normalize(%g) = %g, %t, want %g", value, got, ok, want)
+		}
+	}
+	if _, ok := distributions.Normalize(TraitNeuroticism, 1); ok {
+		t.Error("Normalize with a zero standard deviation succeeded")
+	}
+	if _, ok := distributions.Normalize(TraitExtraversion, 1); ok {
+		t.Error("Normalize of a trait without reference data succeeded")
+	}
+}
+
+func TestNormalized(t *testing.T) {
+	defer func(normalizer Normalizer) { DefaultNormalizer = normalizer }(DefaultNormalizer)
+	DefaultNormalizer = NormalDistributions{TraitOpenness: {Mean: 0, StdDev: 1}}
+
+	predictions := Predictions{Predictions: []PredictionEntry{
+		{Trait: TraitOpenness, Value: 0},
+		{Trait: TraitExtraversion, Value: 0.3},
+	}}
+	normalized := predictions.Normalized()
+	if len(normalized) != 1 || normalized[TraitOpenness] != 50 {
+		t.Errorf("Normalized() = %v, want only %s at 50", normalized, TraitOpenness)
+	}
+}
@@ -0,0 +1,226 @@
+package applymagicsauce
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx that carries id as the correlation ID of the calls made
+// with it. The ID is part of all log lines (see WithLogger) and events (see WithObserver) of these
+// calls, including retries and token renewals. Without an ID, the Client generates one per call.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the correlation ID set with ContextWithRequestID, if any.
+func RequestIDFromContext(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// withRequestID makes sure that ctx carries a correlation ID, if the Client has anyone to report it to.
+func (c *Client) withRequestID(ctx context.Context) context.Context {
+	if c.logger == nil && c.observer == nil {
+		return ctx
+	}
+	if _, ok := RequestIDFromContext(ctx); ok {
+		return ctx
+	}
+	return ContextWithRequestID(ctx, newUUID())
+}
+
+// Logger is the interface of the logger set with WithLogger. It is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger makes the Client log every request it sends, retries and token renewals. Every line
+// starts with the correlation ID of the call (see ContextWithRequestID). The default is to not log.
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) error {
+		if logger == nil {
+			return fmt.Errorf("logger must not be nil")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+func (c *Client) logf(ctx context.Context, format string, v ...interface{}) {
+	if c.logger == nil {
+		return
+	}
+	id, _ := RequestIDFromContext(ctx)
+	c.logger.Printf("[%s] "+format, append([]interface{}{id}, v...)...)
+}
+
+// RequestEvent describes a single HTTP request sent by a Client. See WithObserver.
+type RequestEvent struct {
+	// RequestID is the correlation ID of the call the request belongs to. See ContextWithRequestID.
+	RequestID string
+	// Method is the HTTP method of the request.
+	Method string
+	// Endpoint is the endpoint of the request, e.g. EndpointText.
+	Endpoint string
+	// Attempt is 1 for the first request of a call and greater for retries.
+	Attempt int
+	// StatusCode is the status code of the response. It is zero if there was no response.
+	StatusCode int
+	// Err is the error of the request, if it failed without a response.
+	Err error
+	// Duration is the time the request took.
+	Duration time.Duration
+	// Trace breaks Duration down into the phases of the request. It is only set with WithHTTPTrace.
+	Trace *TraceInfo
+}
+
+// TraceInfo holds the duration of the phases of an HTTP request, as recorded with net/http/httptrace.
+// Phases that did not happen, e.g. DNS and Connect for a reused connection, are zero.
+type TraceInfo struct {
+	// DNS is the time spent looking up the host.
+	DNS time.Duration
+	// Connect is the time spent establishing the TCP connection.
+	Connect time.Duration
+	// TLSHandshake is the time spent in the TLS handshake.
+	TLSHandshake time.Duration
+	// TimeToFirstByte is the time from the start of the request until the first byte of the response,
+	// i.e. including all phases above and the time the API took to answer.
+	TimeToFirstByte time.Duration
+	// ReusedConn is set if the request was sent over a connection that was already open.
+	ReusedConn bool
+}
+
+// WithHTTPTrace makes the Client record the phases of every request (see TraceInfo) and pass them to
+// the function set with WithObserver in RequestEvent.Trace, e.g. to tell a slow network from a slow
+// API. It adds some overhead to every request, so it is disabled by default.
+func WithHTTPTrace(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.httpTrace = enabled
+		return nil
+	}
+}
+
+// traceRecorder collects a TraceInfo. The hooks of httptrace may be called from other goroutines.
+type traceRecorder struct {
+	mu    sync.Mutex
+	start time.Time
+	info  TraceInfo
+
+	dnsStart, connectStart, tlsStart time.Time
+}
+
+// withTrace returns ctx with a trace of the next request, if enabled by WithHTTPTrace.
+func (c *Client) withTrace(ctx context.Context) (context.Context, *traceRecorder) {
+	if !c.httpTrace || c.observer == nil {
+		return ctx, nil
+	}
+
+	r := &traceRecorder{start: time.Now()}
+	since := func(start time.Time) time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return time.Since(start)
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.info.ReusedConn = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.info.DNS = since(r.dnsStart)
+		},
+		ConnectStart: func(_, _ string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, _ error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.info.Connect = since(r.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.info.TLSHandshake = since(r.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.info.TimeToFirstByte = since(r.start)
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), r
+}
+
+// traceInfo returns a copy of the recorded TraceInfo, or nil without a recorder.
+func (r *traceRecorder) traceInfo() *TraceInfo {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info := r.info
+	return &info
+}
+
+// WithObserver sets a function that is called after every HTTP request the Client sends, e.g. to
+// collect metrics or traces. It is called synchronously, so it should return quickly.
+func WithObserver(observe func(RequestEvent)) ClientOption {
+	return func(c *Client) error {
+		if observe == nil {
+			return fmt.Errorf("observer must not be nil")
+		}
+		c.observer = observe
+		return nil
+	}
+}
+
+// observe reports a request to the logger and the observer of the Client.
+func (c *Client) observe(ctx context.Context, method, endpoint string, attempt int, response *response, err error, duration time.Duration, trace *TraceInfo) {
+	if c.logger == nil && c.observer == nil {
+		return
+	}
+
+	event := RequestEvent{
+		Method:   method,
+		Endpoint: endpointPath(endpoint),
+		Attempt:  attempt,
+		Err:      err,
+		Duration: duration,
+		Trace:    trace,
+	}
+	event.RequestID, _ = RequestIDFromContext(ctx)
+	if response != nil {
+		event.StatusCode = response.statusCode
+	}
+
+	if err != nil {
+		c.logf(ctx, "%s %s (attempt %d): %v", event.Method, event.Endpoint, event.Attempt, err)
+	} else {
+		c.logf(ctx, "%s %s (attempt %d): status %d in %s", event.Method, event.Endpoint, event.Attempt, event.StatusCode, event.Duration)
+	}
+	if c.observer != nil {
+		c.observer(event)
+	}
+}
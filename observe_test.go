@@ -0,0 +1,130 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// lineLogger is a Logger that keeps the lines it is given.
+type lineLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *lineLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *lineLogger) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines := l.lines
+	l.lines = nil
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	logger := &lineLogger{}
+	var events []RequestEvent
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == EndpointAuth:
+			w.Write([]byte(`{"token":"renewed","customer_id":1}`))
+		case requests%4 == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.Header.Get("X-Auth-Token") != "renewed":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.Write([]byte(`{"input_used":1}`))
+		}
+	}, WithRetry(2, 0), WithAutoRenew(true), WithLogger(logger), WithObserver(func(event RequestEvent) {
+		events = append(events, event)
+	}))
+
+	// A retry, the rejection of the token, its renewal and the repeated call.
+	call := func(ctx context.Context) (ids map[string]bool) {
+		t.Helper()
+		requests = 0
+		auth := &Token{Token: "old", CustomerID: 1, apiKey: "key"}
+		if _, err := client.PredictLikeIDs(ctx, []string{"1"}, nil, auth); err != nil {
+			t.Fatal(err)
+		}
+		ids = make(map[string]bool)
+		prefix := regexp.MustCompile(`^\[([^\]]+)\] `)
+		lines := logger.take()
+		if len(lines) < 4 {
+			t.Errorf("%d log lines, want one per request: %q", len(lines), lines)
+		}
+		for _, line := range lines {
+			match := prefix.FindStringSubmatch(line)
+			if match == nil {
+				t.Errorf("log line %q has no request id", line)
+				continue
+			}
+			ids[match[1]] = true
+		}
+		for _, event := range events {
+			ids[event.RequestID] = true
+		}
+		events = nil
+		if len(ids) != 1 {
+			t.Errorf("request ids %v, want one for the whole call", ids)
+		}
+		return ids
+	}
+
+	first, second := call(context.Background()), call(context.Background())
+	for id := range first {
+		if second[id] {
+			t.Errorf("two calls share the generated request id %s", id)
+		}
+	}
+	if ids := call(ContextWithRequestID(context.Background(), "job-42")); !ids["job-42"] {
+		t.Errorf("request ids %v, want the id of the context", ids)
+	}
+}
+
+func TestHTTPTrace(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	}
+	for _, enabled := range []bool{false, true} {
+		var traces []*TraceInfo
+		client := newTestClient(t, handler, WithHTTPTrace(enabled), WithObserver(func(event RequestEvent) {
+			traces = append(traces, event.Trace)
+		}))
+		for i := 0; i < 2; i++ {
+			if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(traces) != 2 {
+			t.Fatalf("enabled %t: %d events, want 2", enabled, len(traces))
+		}
+
+		if !enabled {
+			if traces[0] != nil || traces[1] != nil {
+				t.Errorf("traces without WithHTTPTrace: %+v, %+v", traces[0], traces[1])
+			}
+			continue
+		}
+		first, second := traces[0], traces[1]
+		if first == nil || second == nil {
+			t.Fatalf("traces = %v, %v, want both set", first, second)
+		}
+		if first.ReusedConn || first.Connect <= 0 || first.TimeToFirstByte <= 0 {
+			t.Errorf("first request = %+v, want a new connection", *first)
+		}
+		if !second.ReusedConn || second.Connect != 0 || second.TimeToFirstByte <= 0 {
+			t.Errorf("second request = %+v, want the connection of the first", *second)
+		}
+	}
+}
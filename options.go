@@ -0,0 +1,185 @@
+package applymagicsauce
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrMissingSource is returned if options for PredictText have no OptionsSource.
+var ErrMissingSource = errors.New("missing source")
+
+var knownSources = map[string]bool{
+	SourceWebsite:      true,
+	SourceEmail:        true,
+	SourceBrochure:     true,
+	SourceStatusUpdate: true,
+	SourceTweet:        true,
+	SourceCV:           true,
+	SourceOther:        true,
+}
+
+// TextOptions builds options for PredictText step by step, e.g.
+//
+//	options, err := NewTextOptions().Source(SourceEmail).Traits(TraitOpenness).Interpretations(true).Build()
+//
+// It is an alternative to PredictTextOptions. The options are only validated by Build.
+type TextOptions struct {
+	source          string
+	traits          []string
+	interpretations bool
+}
+
+// NewTextOptions returns an empty TextOptions. Source must be set before calling Build.
+func NewTextOptions() *TextOptions {
+	return &TextOptions{}
+}
+
+// Source sets OptionsSource to one of the Source constants.
+func (o *TextOptions) Source(source string) *TextOptions {
+	o.source = source
+	return o
+}
+
+// Traits adds traits to OptionsTraits.
+func (o *TextOptions) Traits(traits ...string) *TextOptions {
+	o.traits = append(o.traits, traits...)
+	return o
+}
+
+// Interpretations sets whether interpretations are requested.
+func (o *TextOptions) Interpretations(interpretations bool) *TextOptions {
+	o.interpretations = interpretations
+	return o
+}
+
+// Build validates the options with ValidateOptions and returns them in the form of PredictTextOptions.
+// The source must be one of the Source constants (ErrMissingSource if it was not set) and the traits
+// must be known.
+func (o *TextOptions) Build() (url.Values, error) {
+	options := PredictTextOptions(o.source, o.traits, o.interpretations)
+	if err := ValidateOptions(EndpointText, options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// LikeIDsOptions builds options for PredictLikeIDs step by step, e.g.
+//
+//	options, err := NewLikeIDsOptions().Traits(TraitOpenness).Contributors(true).Build()
+//
+// It is an alternative to PredictLikeIDsOptions. The options are only validated by Build.
+type LikeIDsOptions struct {
+	traits          []string
+	interpretations bool
+	contributors    bool
+}
+
+// NewLikeIDsOptions returns an empty LikeIDsOptions.
+func NewLikeIDsOptions() *LikeIDsOptions {
+	return &LikeIDsOptions{}
+}
+
+// Traits adds traits to OptionsTraits.
+func (o *LikeIDsOptions) Traits(traits ...string) *LikeIDsOptions {
+	o.traits = append(o.traits, traits...)
+	return o
+}
+
+// Interpretations sets whether interpretations are requested.
+func (o *LikeIDsOptions) Interpretations(interpretations bool) *LikeIDsOptions {
+	o.interpretations = interpretations
+	return o
+}
+
+// Contributors sets whether contributors are requested.
+func (o *LikeIDsOptions) Contributors(contributors bool) *LikeIDsOptions {
+	o.contributors = contributors
+	return o
+}
+
+// Build validates the options with ValidateOptions and returns them in the form of
+// PredictLikeIDsOptions. The traits must be known.
+func (o *LikeIDsOptions) Build() (url.Values, error) {
+	options := PredictLikeIDsOptions(o.traits, o.interpretations, o.contributors)
+	if err := ValidateOptions(EndpointLikeIDs, options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// ValidateOptions checks options for a call to endpoint (EndpointText or EndpointLikeIDs) without
+// sending anything:
+//
+//   - for EndpointText, OptionsSource must be one of the Source constants (ErrMissingSource if it is
+//     missing) and OptionsContributors must not be set (ErrContributorsNotSupported),
+//   - OptionsInterpretations and OptionsContributors must be "true" or "false",
+//   - the traits must be known (see ValidateTraits).
+//
+// A Client runs the same checks before every prediction, except for the traits if
+// WithAllowUnknownTraits is used. The package-level functions skip them.
+func ValidateOptions(endpoint string, options url.Values) error {
+	return validateOptions(endpoint, options, false)
+}
+
+func validateOptions(endpoint string, options url.Values, allowUnknownTraits bool) error {
+	if endpointPath(endpoint) == EndpointText {
+		switch source := options.Get(OptionsSource); {
+		case source == "":
+			return ErrMissingSource
+		case !knownSources[source]:
+			return fmt.Errorf("unknown source: %s", source)
+		}
+		if _, ok := options[OptionsContributors]; ok {
+			return ErrContributorsNotSupported
+		}
+	}
+
+	for _, key := range []string{OptionsInterpretations, OptionsContributors} {
+		for _, value := range options[key] {
+			if value != "true" && value != "false" {
+				return fmt.Errorf("invalid value for %s: %q (must be true or false)", key, value)
+			}
+		}
+	}
+
+	if allowUnknownTraits {
+		return nil
+	}
+	return ValidateTraits(requestedTraits(options))
+}
+
+func (c *Client) checkOptions(endpoint string, options url.Values) error {
+	if c.skipOptionChecks {
+		return nil
+	}
+	return validateOptions(endpoint, options, c.allowUnknownTraits)
+}
+
+// WithDefaultTextOptions sets the options PredictText uses for calls without options (nil). Calls with
+// options use only those, the defaults are not merged into them. The defaults are checked like the
+// options of every call (see ValidateOptions).
+func WithDefaultTextOptions(options url.Values) ClientOption {
+	return func(c *Client) error {
+		c.defaultTextOptions = cloneValues(options)
+		return nil
+	}
+}
+
+// WithDefaultLikeOptions sets the options PredictLikeIDs and PredictPrepared use for calls without
+// options (nil). Calls with options use only those, the defaults are not merged into them. See
+// WithDefaultTextOptions.
+func WithDefaultLikeOptions(options url.Values) ClientOption {
+	return func(c *Client) error {
+		c.defaultLikeOptions = cloneValues(options)
+		return nil
+	}
+}
+
+// withDefaults returns options, or the defaults if options is nil.
+func withDefaults(options, defaults url.Values) url.Values {
+	if options == nil {
+		return defaults
+	}
+	return options
+}
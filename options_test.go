@@ -0,0 +1,185 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDefaultOptions(t *testing.T) {
+	textDefaults := PredictTextOptions(SourceEmail, []string{TraitOpenness}, false)
+	likeDefaults := PredictLikeIDsOptions([]string{TraitNeuroticism}, false, false)
+	own := PredictTextOptions(SourceTweet, []string{TraitExtraversion}, false)
+
+	tests := []struct {
+		name string
+		call func(client *Client) error
+		want map[string]url.Values
+	}{
+		{
+			name: "text defaults",
+			call: func(client *Client) error {
+				_, err := client.PredictText(context.Background(), "text", nil, StubToken())
+				return err
+			},
+			want: map[string]url.Values{EndpointText: textDefaults},
+		},
+		{
+			name: "own text options",
+			call: func(client *Client) error {
+				_, err := client.PredictText(context.Background(), "text", own, StubToken())
+				return err
+			},
+			want: map[string]url.Values{EndpointText: own},
+		},
+		{
+			name: "like defaults",
+			call: func(client *Client) error {
+				_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+				return err
+			},
+			want: map[string]url.Values{EndpointLikeIDs: likeDefaults},
+		},
+		{
+			name: "analyze defaults",
+			call: func(client *Client) error {
+				_, err := client.Analyze(context.Background(), "text", SourceCV, []string{"1"}, nil, StubToken())
+				return err
+			},
+			want: map[string]url.Values{
+				EndpointText:    PredictTextOptions(SourceCV, []string{TraitOpenness}, false),
+				EndpointLikeIDs: likeDefaults,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			got := make(map[string]url.Values)
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got[r.URL.Path] = r.URL.Query()
+				mu.Unlock()
+				w.Write([]byte(`{"input_used":1}`))
+			}, WithDefaultTextOptions(textDefaults), WithDefaultLikeOptions(likeDefaults))
+
+			if err := test.call(client); err != nil {
+				t.Fatal(err)
+			}
+			for endpoint, want := range test.want {
+				if got[endpoint].Encode() != want.Encode() {
+					t.Errorf("options of %s = %q, want %q", endpoint, got[endpoint].Encode(), want.Encode())
+				}
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("requests to %v, want %d endpoints", got, len(test.want))
+			}
+		})
+	}
+}
+
+func TestOptionsBuilders(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func() (url.Values, error)
+		want    url.Values
+		wantErr error
+	}{
+		{"text", NewTextOptions().Source(SourceEmail).Traits(TraitOpenness).Traits(TraitExtraversion).Interpretations(true).Build,
+			PredictTextOptions(SourceEmail, []string{TraitExtraversion, TraitOpenness}, true), nil},
+		{"text without source", NewTextOptions().Traits(TraitOpenness).Build, nil, ErrMissingSource},
+		{"text with unknown source", NewTextOptions().Source("FAX").Build, nil, nil},
+		{"text with unknown trait", NewTextOptions().Source(SourceEmail).Traits("OPE").Build, nil, &UnknownTraitsError{}},
+		{"likes", NewLikeIDsOptions().Traits(TraitAge).Contributors(true).Build,
+			PredictLikeIDsOptions([]string{TraitAge}, false, true), nil},
+		{"empty likes", NewLikeIDsOptions().Build, url.Values{}, nil},
+		{"likes with unknown trait", NewLikeIDsOptions().Traits("OPE").Build, nil, &UnknownTraitsError{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := test.build()
+			if test.want != nil {
+				if err != nil || !reflect.DeepEqual(options, test.want) {
+					t.Errorf("Build = %v, %v, want %v", options, err, test.want)
+				}
+				return
+			}
+
+			var unknown *UnknownTraitsError
+			switch {
+			case err == nil || options != nil:
+				t.Errorf("Build = %v, %v, want an error", options, err)
+			case test.wantErr == nil:
+			case errors.As(test.wantErr, &unknown):
+				if !errors.As(err, &unknown) {
+					t.Errorf("err = %v, want an *UnknownTraitsError", err)
+				}
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		options  url.Values
+		wantErr  error
+		wantMsg  string
+	}{
+		{"valid text", EndpointText, url.Values{OptionsSource: {SourceTweet}, OptionsTraits: {TraitAge}, OptionsInterpretations: {"true"}}, nil, ""},
+		{"valid likes", EndpointLikeIDs, url.Values{OptionsContributors: {"false"}, OptionsTraits: {TraitOpenness + "," + TraitAge}}, nil, ""},
+		{"missing source", EndpointText, url.Values{}, ErrMissingSource, ""},
+		{"unknown source", EndpointText, url.Values{OptionsSource: {"FAX"}}, nil, "unknown source: FAX"},
+		{"contributors on text", EndpointText, url.Values{OptionsSource: {SourceTweet}, OptionsContributors: {"false"}}, ErrContributorsNotSupported, ""},
+		{"invalid contributors on text", EndpointText, url.Values{OptionsSource: {SourceTweet}, OptionsContributors: {"maybe"}}, ErrContributorsNotSupported, ""},
+		{"source not needed for likes", EndpointLikeIDs, url.Values{}, nil, ""},
+		{"invalid boolean", EndpointLikeIDs, url.Values{OptionsInterpretations: {"yes"}}, nil, `invalid value for interpretations: "yes"`},
+		{"invalid repeated boolean", EndpointLikeIDs, url.Values{OptionsContributors: {"true", "1"}}, nil, `invalid value for contributors: "1"`},
+		{"unknown trait", EndpointLikeIDs, url.Values{OptionsTraits: {TraitAge + ",OPE"}}, nil, "unknown traits: OPE"},
+		{"endpoint with query", EndpointText + "?x=1", url.Values{}, ErrMissingSource, ""},
+	}
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateOptions(test.endpoint, test.options)
+
+			// The predict functions check the options the same way before sending anything.
+			var predictErr error
+			if endpointPath(test.endpoint) == EndpointText {
+				_, predictErr = client.PredictText(context.Background(), "text", test.options, StubToken())
+			} else {
+				_, predictErr = client.PredictLikeIDs(context.Background(), []string{"1"}, test.options, StubToken())
+			}
+			if fmt.Sprint(predictErr) != fmt.Sprint(err) {
+				t.Errorf("predict err = %v, want the error of ValidateOptions: %v", predictErr, err)
+			}
+
+			switch {
+			case test.wantErr == nil && test.wantMsg == "":
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+			case test.wantErr != nil:
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("err = %v, want %v", err, test.wantErr)
+				}
+			case err == nil || !strings.Contains(err.Error(), test.wantMsg):
+				t.Errorf("err = %v, want it to mention %q", err, test.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package applymagicsauce
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPayloadTooLarge is returned by the predict functions if the encoded input is larger than allowed
+// by WithMaxRequestBytes.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// WithMaxRequestBytes sets the maximum size of the body of a prediction request: the text for
+// PredictText and the encoded JSON list for PredictLikeIDs. Larger inputs are not sent, the call fails
+// with ErrPayloadTooLarge instead. With WithLikeIDBatching, the limit applies to every batch. The
+// default is zero, which means no limit.
+func WithMaxRequestBytes(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("maximum request size must not be negative")
+		}
+		c.maxRequestBytes = n
+		return nil
+	}
+}
+
+func (c *Client) checkPayloadSize(payload []byte) error {
+	if c.maxRequestBytes > 0 && len(payload) > c.maxRequestBytes {
+		return fmt.Errorf("%w: %d bytes (maximum is %d)", ErrPayloadTooLarge, len(payload), c.maxRequestBytes)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaxRequestBytes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		options []ClientOption
+		call    func(c *Client) error
+		wantErr bool
+	}{
+		{"text at the limit", nil, func(c *Client) error {
+			_, err := c.PredictText(ctx, strings.Repeat("x", 20), MinimalBigFiveOptions(SourceOther), StubToken())
+			return err
+		}, false},
+		{"oversized text", nil, func(c *Client) error {
+			_, err := c.PredictText(ctx, strings.Repeat("x", 21), MinimalBigFiveOptions(SourceOther), StubToken())
+			return err
+		}, true},
+		// ["1","2","3","4"] is 17 bytes, one more ID makes it 21.
+		{"like ids below the limit", nil, func(c *Client) error {
+			_, err := c.PredictLikeIDs(ctx, []string{"1", "2", "3", "4"}, nil, StubToken())
+			return err
+		}, false},
+		{"oversized like ids", nil, func(c *Client) error {
+			_, err := c.PredictLikeIDs(ctx, []string{"1", "2", "3", "4", "5"}, nil, StubToken())
+			return err
+		}, true},
+		{"batches below the limit", []ClientOption{WithMaxLikeIDs(2), WithLikeIDBatching(true)}, func(c *Client) error {
+			_, err := c.PredictLikeIDs(ctx, []string{"1", "2", "3", "4", "5"}, nil, StubToken())
+			return err
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte(`{"input_used":1}`))
+			}, append([]ClientOption{WithMaxRequestBytes(20)}, test.options...)...)
+
+			err := test.call(client)
+			if !test.wantErr {
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrPayloadTooLarge) {
+				t.Errorf("err = %v, want ErrPayloadTooLarge", err)
+			}
+			if requests != 0 {
+				t.Errorf("%d requests sent for an oversized payload", requests)
+			}
+		})
+	}
+}
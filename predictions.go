@@ -0,0 +1,617 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BigFive holds the values of the five core personality traits.
+type BigFive struct {
+	Openness          float64
+	Conscientiousness float64
+	Extraversion      float64
+	Agreeableness     float64
+	Neuroticism       float64
+}
+
+// Value returns the predicted value of trait and whether it was predicted.
+func (p Predictions) Value(trait string) (value float64, ok bool) {
+	for _, prediction := range p.Predictions {
+		if prediction.Trait == trait {
+			return prediction.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Interpretation returns the interpretation of trait and whether there is one.
+func (p Predictions) Interpretation(trait string) (value interface{}, ok bool) {
+	for _, interpretation := range p.Interpretations {
+		if interpretation.Trait == trait {
+			return interpretation.Value, true
+		}
+	}
+	return nil, false
+}
+
+// TraitDetail combines the predicted value of a trait with its interpretation. See
+// Predictions.TraitDetail.
+type TraitDetail struct {
+	Value          float64
+	Interpretation interface{}
+
+	HasValue          bool
+	HasInterpretation bool
+}
+
+// TraitDetail returns the predicted value and the interpretation of trait. Either of them may be
+// missing, see TraitDetail.HasValue and TraitDetail.HasInterpretation. ok is false if there is
+// neither.
+func (p Predictions) TraitDetail(trait string) (detail TraitDetail, ok bool) {
+	detail.Value, detail.HasValue = p.Value(trait)
+	detail.Interpretation, detail.HasInterpretation = p.Interpretation(trait)
+	return detail, detail.HasValue || detail.HasInterpretation
+}
+
+// BigFive returns the values of the five core personality traits. ok is false unless all five have
+// been predicted.
+func (p Predictions) BigFive() (bigFive BigFive, ok bool) {
+	traits := []struct {
+		trait string
+		value *float64
+	}{
+		{TraitOpenness, &bigFive.Openness},
+		{TraitConscientiousness, &bigFive.Conscientiousness},
+		{TraitExtraversion, &bigFive.Extraversion},
+		{TraitAgreeableness, &bigFive.Agreeableness},
+		{TraitNeuroticism, &bigFive.Neuroticism},
+	}
+	for _, t := range traits {
+		value, found := p.Value(t.trait)
+		if !found {
+			return BigFive{}, false
+		}
+		*t.value = value
+	}
+	return bigFive, true
+}
+
+// TopContributors returns the first n positive and negative contributors of trait. The API lists the
+// contributors ordered by their influence, so these are the n most influential ones. A negative n
+// returns all of them.
+func (p Predictions) TopContributors(trait string, n int) (positive, negative []string) {
+	for _, contributor := range p.Contributors {
+		if contributor.Trait == trait {
+			return firstN(contributor.Positive, n), firstN(contributor.Negative, n)
+		}
+	}
+	return nil, nil
+}
+
+func firstN(list []string, n int) []string {
+	if n < 0 || n > len(list) {
+		n = len(list)
+	}
+	return append([]string(nil), list[:n]...)
+}
+
+// MissingTraits returns the requested traits the API did not return a prediction for. If requested is
+// nil, the traits recorded in Requested are used. Traits that make the API return several related
+// predictions (e.g. TraitPolitics) count as present if any of them was returned.
+func (p Predictions) MissingTraits(requested []string) []string {
+	if requested == nil {
+		requested = p.Requested
+	}
+
+	var missing []string
+	for _, trait := range requested {
+		found := false
+		for _, prediction := range p.Predictions {
+			if prediction.Trait == trait || strings.HasPrefix(prediction.Trait, trait+"_") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, trait)
+		}
+	}
+	return missing
+}
+
+// Duplicates returns the traits that occur more than once in Predictions, Interpretations or
+// Contributors, sorted and each listed once. Some versions of the API have been seen to send the same
+// trait twice; then the helpers (e.g. Value) only see the first entry and loops count the trait twice.
+// See also WithStrictResponses.
+func (p Predictions) Duplicates() []string {
+	duplicates := make(map[string]bool)
+	countTraits := func(n int, trait func(i int) string) {
+		seen := make(map[string]bool, n)
+		for i := 0; i < n; i++ {
+			if seen[trait(i)] {
+				duplicates[trait(i)] = true
+			}
+			seen[trait(i)] = true
+		}
+	}
+	countTraits(len(p.Predictions), func(i int) string { return p.Predictions[i].Trait })
+	countTraits(len(p.Interpretations), func(i int) string { return p.Interpretations[i].Trait })
+	countTraits(len(p.Contributors), func(i int) string { return p.Contributors[i].Trait })
+
+	if len(duplicates) == 0 {
+		return nil
+	}
+	traits := make([]string, 0, len(duplicates))
+	for trait := range duplicates {
+		traits = append(traits, trait)
+	}
+	sort.Strings(traits)
+	return traits
+}
+
+// maxPlausibleValue is the largest absolute value of a prediction Validate accepts. The predictions of
+// the API are scores, probabilities or ages, far below it.
+const maxPlausibleValue = 1000
+
+// ErrInvalidPredictions is returned by Predictions.Validate if the Predictions break an invariant of
+// the responses of the API.
+var ErrInvalidPredictions = errors.New("invalid predictions")
+
+// Validate checks the invariants of a response of the API, to turn a change of the response format into
+// an explicit error instead of silently wrong results: every prediction, interpretation and
+// contributor has a trait, every value is a finite number of plausible size and no list of
+// contributing Like IDs contains an empty ID. The error wraps ErrInvalidPredictions and lists all
+// violations.
+//
+// The predict functions of a Client only call Validate on the responses of the API with
+// WithStrictResponses. Otherwise it only runs when called, e.g. on Predictions loaded from elsewhere.
+func (p Predictions) Validate() error {
+	var problems []string
+	for i, prediction := range p.Predictions {
+		if prediction.Trait == "" {
+			problems = append(problems, fmt.Sprintf("prediction %d has no trait", i))
+		}
+		if math.IsNaN(prediction.Value) || math.Abs(prediction.Value) > maxPlausibleValue {
+			problems = append(problems, fmt.Sprintf("prediction %d (%s) has implausible value %v", i, prediction.Trait, prediction.Value))
+		}
+	}
+	for i, interpretation := range p.Interpretations {
+		if interpretation.Trait == "" {
+			problems = append(problems, fmt.Sprintf("interpretation %d has no trait", i))
+		}
+	}
+	for i, contributor := range p.Contributors {
+		if contributor.Trait == "" {
+			problems = append(problems, fmt.Sprintf("contributor entry %d has no trait", i))
+		}
+		for _, ids := range [][]string{contributor.Positive, contributor.Negative} {
+			for _, id := range ids {
+				if id == "" {
+					problems = append(problems, fmt.Sprintf("contributor entry %d (%s) contains an empty like id", i, contributor.Trait))
+					break
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidPredictions, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// IsEmpty reports whether the Predictions contain nothing: no predictions and no used input. This is
+// the case if the API answered with 204 No Content, e.g. because none of the input could be used.
+// Such a call does not return an error.
+func (p Predictions) IsEmpty() bool {
+	return len(p.Predictions) == 0 && p.InputUsed == 0
+}
+
+// UnmarshalJSON decodes Predictions and keeps any fields of the response it does not know about, e.g.
+// notices the API may add in the future. See Extra.
+func (p *Predictions) UnmarshalJSON(data []byte) error {
+	return p.decodeFrom(json.NewDecoder(bytes.NewReader(data)))
+}
+
+// decodeFrom decodes Predictions from the next value of decoder. The value is decoded in a single pass,
+// field by field, so that the unknown fields and the raw interpretations come without decoding it
+// again. The lists are decoded entry by entry, so that the decoder never holds more than one entry of
+// them; this is what lets a Client decode large responses straight from the connection.
+func (p *Predictions) decodeFrom(decoder *json.Decoder) error {
+	start, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if start == nil {
+		return nil
+	}
+	if start != json.Delim('{') {
+		return fmt.Errorf("cannot decode predictions from %v", start)
+	}
+
+	p.extra = nil
+	p.rawInterpretations = nil
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch name := key.(string); name {
+		case "input_used":
+			err = decoder.Decode(&p.InputUsed)
+		case "predictions":
+			p.Predictions, err = decodePredictionEntries(decoder)
+		case "interpretations":
+			err = p.decodeInterpretations(decoder)
+		case "contributors":
+			p.Contributors, err = decodeContributorEntries(decoder)
+		case "model_version":
+			var value json.RawMessage
+			if err = decoder.Decode(&value); err == nil {
+				p.ModelVersion = rawString(value)
+			}
+		default:
+			var value json.RawMessage
+			if err = decoder.Decode(&value); err == nil {
+				if p.extra == nil {
+					p.extra = make(map[string]json.RawMessage)
+				}
+				p.extra[name] = value
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// The closing brace.
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	p.fallBackToModelVersionFields()
+	return nil
+}
+
+// decodeWith decodes data like UnmarshalJSON, but with codec instead of encoding/json. A codec would
+// call UnmarshalJSON, so the response is split into its fields with codec and each of them is decoded
+// with it.
+func (p *Predictions) decodeWith(codec JSONCodec, data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := codec.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	p.extra = nil
+	p.rawInterpretations = nil
+	for name, value := range fields {
+		var err error
+		switch name {
+		case "input_used":
+			err = codec.Unmarshal(value, &p.InputUsed)
+		case "predictions":
+			err = codec.Unmarshal(value, &p.Predictions)
+		case "interpretations":
+			var interpretations []rawInterpretation
+			if err = codec.Unmarshal(value, &interpretations); err == nil {
+				err = p.setInterpretations(interpretations, codec.Unmarshal)
+			}
+		case "contributors":
+			err = codec.Unmarshal(value, &p.Contributors)
+		case "model_version":
+			p.ModelVersion = rawString(value)
+		default:
+			if p.extra == nil {
+				p.extra = make(map[string]json.RawMessage)
+			}
+			p.extra[name] = value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	p.fallBackToModelVersionFields()
+	return nil
+}
+
+// decodeArray decodes the array that is the next value of decoder with decodeEntry, which is called
+// once per entry. It reports whether the value was null instead of an array.
+func decodeArray(decoder *json.Decoder, decodeEntry func() error) (null bool, err error) {
+	start, err := decoder.Token()
+	if err != nil {
+		return false, err
+	}
+	if start == nil {
+		return true, nil
+	}
+	if start != json.Delim('[') {
+		return false, fmt.Errorf("cannot decode an array from %v", start)
+	}
+	for decoder.More() {
+		if err := decodeEntry(); err != nil {
+			return false, err
+		}
+	}
+	_, err = decoder.Token()
+	return false, err
+}
+
+func decodePredictionEntries(decoder *json.Decoder) ([]PredictionEntry, error) {
+	entries := []PredictionEntry{}
+	null, err := decodeArray(decoder, func() error {
+		var entry PredictionEntry
+		err := decoder.Decode(&entry)
+		entries = append(entries, entry)
+		return err
+	})
+	if null {
+		return nil, err
+	}
+	return entries, err
+}
+
+func decodeContributorEntries(decoder *json.Decoder) ([]ContributorEntry, error) {
+	entries := []ContributorEntry{}
+	null, err := decodeArray(decoder, func() error {
+		var entry ContributorEntry
+		err := decoder.Decode(&entry)
+		entries = append(entries, entry)
+		return err
+	})
+	if null {
+		return nil, err
+	}
+	return entries, err
+}
+
+// rawInterpretation is an interpretation with its value as sent by the API.
+type rawInterpretation struct {
+	Trait string          `json:"trait"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodeInterpretations decodes the interpretations from decoder into Interpretations and keeps their
+// raw values for InterpretationRaw.
+func (p *Predictions) decodeInterpretations(decoder *json.Decoder) error {
+	interpretations := []rawInterpretation{}
+	null, err := decodeArray(decoder, func() error {
+		var interpretation rawInterpretation
+		err := decoder.Decode(&interpretation)
+		interpretations = append(interpretations, interpretation)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if null {
+		interpretations = nil
+	}
+	return p.setInterpretations(interpretations, json.Unmarshal)
+}
+
+// setInterpretations sets Interpretations to interpretations, with the values decoded by unmarshal,
+// and keeps the raw values for InterpretationRaw.
+func (p *Predictions) setInterpretations(interpretations []rawInterpretation, unmarshal func([]byte, interface{}) error) error {
+	if interpretations == nil {
+		p.Interpretations = nil
+		return nil
+	}
+
+	p.Interpretations = make([]InterpretationEntry, len(interpretations))
+	p.rawInterpretations = make(map[string]json.RawMessage, len(interpretations))
+	for i, interpretation := range interpretations {
+		p.Interpretations[i].Trait = interpretation.Trait
+		if len(interpretation.Value) > 0 {
+			if err := unmarshal(interpretation.Value, &p.Interpretations[i].Value); err != nil {
+				return err
+			}
+		}
+		p.rawInterpretations[interpretation.Trait] = interpretation.Value
+	}
+	return nil
+}
+
+// modelVersionFields are the names besides "model_version" a model version is taken from, in this
+// order. They stay available with Extra.
+var modelVersionFields = []string{"modelVersion", "model", "version"}
+
+// fallBackToModelVersionFields takes ModelVersion from modelVersionFields if the response had no
+// model_version.
+func (p *Predictions) fallBackToModelVersionFields() {
+	for _, name := range modelVersionFields {
+		if p.ModelVersion != "" {
+			return
+		}
+		if value, ok := p.extra[name]; ok {
+			p.ModelVersion = rawString(value)
+		}
+	}
+}
+
+// rawString returns value unquoted if it is a JSON string and as it is otherwise (e.g. for a number).
+func rawString(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// Extra returns the raw value of a field of the response that Predictions has no field for, e.g. a
+// notice added by the API, and whether the response had such a field.
+func (p Predictions) Extra(key string) (value json.RawMessage, ok bool) {
+	value, ok = p.extra[key]
+	if !ok {
+		return nil, false
+	}
+	return append(json.RawMessage(nil), value...), true
+}
+
+// InterpretationRaw returns the value of the interpretation of trait exactly as the API sent it, e.g.
+// to pass it on without the loss of precision of InterpretationEntry.Value (large numbers become
+// float64 there), and whether there is an interpretation for trait. For Predictions that were not
+// decoded from a response, the value is encoded from Interpretations.
+func (p Predictions) InterpretationRaw(trait string) (value json.RawMessage, ok bool) {
+	for _, interpretation := range p.Interpretations {
+		if interpretation.Trait != trait {
+			continue
+		}
+		if raw, ok := p.rawInterpretations[trait]; ok {
+			return append(json.RawMessage(nil), raw...), true
+		}
+		raw, err := json.Marshal(interpretation.Value)
+		if err != nil {
+			return nil, false
+		}
+		return raw, true
+	}
+	return nil, false
+}
+
+// String returns a compact summary of the predicted values, e.g. for logging.
+func (p Predictions) String() string {
+	values := make([]string, len(p.Predictions))
+	for i, prediction := range p.Predictions {
+		values[i] = fmt.Sprintf("%s: %.3g", prediction.Trait, prediction.Value)
+	}
+	return fmt.Sprintf("Predictions{input used: %d, %s}", p.InputUsed, strings.Join(values, ", "))
+}
+
+// Filter returns a copy of the Predictions with only the predictions for which keep returns true. The
+// interpretations and contributors are filtered to the same traits. The receiver is not modified.
+func (p Predictions) Filter(keep func(trait string, value float64) bool) Predictions {
+	filtered := p.Clone()
+	all := filtered
+	filtered.Predictions = nil
+	filtered.Interpretations = nil
+	filtered.Contributors = nil
+
+	kept := make(map[string]bool)
+	for _, prediction := range all.Predictions {
+		if keep(prediction.Trait, prediction.Value) {
+			kept[prediction.Trait] = true
+			filtered.Predictions = append(filtered.Predictions, prediction)
+		}
+	}
+	for _, interpretation := range all.Interpretations {
+		if kept[interpretation.Trait] {
+			filtered.Interpretations = append(filtered.Interpretations, interpretation)
+		}
+	}
+	for _, contributor := range all.Contributors {
+		if kept[contributor.Trait] {
+			filtered.Contributors = append(filtered.Contributors, contributor)
+		}
+	}
+	return filtered
+}
+
+// Clone returns a deep copy of the Predictions: changing the copy, including the Like IDs of the
+// contributors and decoded interpretation values, does not affect the receiver and vice versa.
+func (p Predictions) Clone() Predictions {
+	clone := p
+	if p.Predictions != nil {
+		clone.Predictions = append([]PredictionEntry(nil), p.Predictions...)
+	}
+	if p.Interpretations != nil {
+		clone.Interpretations = make([]InterpretationEntry, len(p.Interpretations))
+		for i, interpretation := range p.Interpretations {
+			interpretation.Value = cloneJSONValue(interpretation.Value)
+			clone.Interpretations[i] = interpretation
+		}
+	}
+	if p.Contributors != nil {
+		clone.Contributors = make([]ContributorEntry, len(p.Contributors))
+		for i, contributor := range p.Contributors {
+			contributor.Positive = cloneStrings(contributor.Positive)
+			contributor.Negative = cloneStrings(contributor.Negative)
+			clone.Contributors[i] = contributor
+		}
+	}
+	clone.Requested = cloneStrings(p.Requested)
+	clone.InputRef.LikeIDs = cloneStrings(p.InputRef.LikeIDs)
+	clone.extra = cloneRawMessages(p.extra)
+	clone.rawInterpretations = cloneRawMessages(p.rawInterpretations)
+	return clone
+}
+
+// cloneStrings returns a copy of s, keeping nil as nil.
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+func cloneRawMessages(messages map[string]json.RawMessage) map[string]json.RawMessage {
+	if messages == nil {
+		return nil
+	}
+	clone := make(map[string]json.RawMessage, len(messages))
+	for key, message := range messages {
+		clone[key] = append(json.RawMessage(nil), message...)
+	}
+	return clone
+}
+
+// cloneJSONValue returns a deep copy of a value decoded by encoding/json into an interface{}.
+func cloneJSONValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(value))
+		for key, element := range value {
+			clone[key] = cloneJSONValue(element)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(value))
+		for i, element := range value {
+			clone[i] = cloneJSONValue(element)
+		}
+		return clone
+	default:
+		return value
+	}
+}
+
+// Above returns the predictions with a value greater than threshold. See Filter.
+func (p Predictions) Above(threshold float64) Predictions {
+	return p.Filter(func(_ string, value float64) bool {
+		return value > threshold
+	})
+}
+
+// Below returns the predictions with a value less than threshold. See Filter.
+func (p Predictions) Below(threshold float64) Predictions {
+	return p.Filter(func(_ string, value float64) bool {
+		return value < threshold
+	})
+}
+
+// SortedByValue returns a copy of the predictions sorted by value, in descending order if desc is set.
+// Predictions with equal values are ordered by trait name. The receiver is not modified.
+func (p Predictions) SortedByValue(desc bool) []PredictionEntry {
+	sorted := append([]PredictionEntry(nil), p.Predictions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Value != sorted[j].Value {
+			if desc {
+				return sorted[i].Value > sorted[j].Value
+			}
+			return sorted[i].Value < sorted[j].Value
+		}
+		return sorted[i].Trait < sorted[j].Trait
+	})
+	return sorted
+}
+
+// SortedByTrait returns a copy of the predictions sorted by trait name. The receiver is not modified.
+func (p Predictions) SortedByTrait() []PredictionEntry {
+	sorted := append([]PredictionEntry(nil), p.Predictions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Trait < sorted[j].Trait
+	})
+	return sorted
+}
@@ -0,0 +1,455 @@
+package applymagicsauce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPredictionsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		want      Predictions
+		wantExtra map[string]string
+		wantErr   bool
+	}{
+		{
+			name: "known fields",
+			data: `{"input_used":2,"predictions":[{"trait":"BIG5_Openness","value":0.5}],"contributors":[{"trait":"BIG5_Openness","positive":["1"],"negative":[]}]}`,
+			want: Predictions{
+				InputUsed:    2,
+				Predictions:  []PredictionEntry{{Trait: TraitOpenness, Value: 0.5}},
+				Contributors: []ContributorEntry{{Trait: TraitOpenness, Positive: []string{"1"}, Negative: []string{}}},
+			},
+		},
+		{
+			name:      "interpretations",
+			data:      `{"interpretations":[{"trait":"Age","value":12345678901234567890},{"trait":"Gender","value":"female"}]}`,
+			want:      Predictions{Interpretations: []InterpretationEntry{{Trait: "Age", Value: 12345678901234567890.0}, {Trait: "Gender", Value: "female"}}},
+			wantExtra: map[string]string{},
+		},
+		{
+			name:      "unknown fields",
+			data:      `{"input_used":1,"notice":{"text":"deprecated"},"Input_Used":5}`,
+			want:      Predictions{InputUsed: 1},
+			wantExtra: map[string]string{"notice": `{"text":"deprecated"}`, "Input_Used": "5"},
+		},
+		{
+			name: "null",
+			data: `null`,
+		},
+		{
+			name:    "not an object",
+			data:    `[1]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid field",
+			data:    `{"input_used":"many"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got Predictions
+			err := json.Unmarshal([]byte(test.data), &got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			for key, want := range test.wantExtra {
+				if value, ok := got.Extra(key); !ok || string(value) != want {
+					t.Errorf("Extra(%q) = %s, %v, want %s", key, value, ok, want)
+				}
+			}
+			if len(got.extra) != len(test.wantExtra) {
+				t.Errorf("got %d extra fields, want %d", len(got.extra), len(test.wantExtra))
+			}
+			got.extra, got.rawInterpretations = nil, nil
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInterpretationRaw(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1,"interpretations":[` +
+			`{"trait":"Age","value":12345678901234567890},` +
+			`{"trait":"Political","value":{"Liberal":0.12345678901234567890123, "Other":[1.0]}}]}`))
+	})
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for trait, want := range map[string]string{
+		"Age":       "12345678901234567890",
+		"Political": `{"Liberal":0.12345678901234567890123, "Other":[1.0]}`,
+	} {
+		value, ok := predictions.InterpretationRaw(trait)
+		if !ok || string(value) != want {
+			t.Errorf("InterpretationRaw(%s) = %s, %v, want %s", trait, value, ok, want)
+		}
+	}
+	value, _ := predictions.InterpretationRaw("Age")
+	value[0] = '9'
+	if value, _ := predictions.InterpretationRaw("Age"); string(value) != "12345678901234567890" {
+		t.Errorf("InterpretationRaw(Age) = %s after modifying a returned value", value)
+	}
+	if value, ok := predictions.InterpretationRaw("Gender"); ok {
+		t.Errorf("InterpretationRaw(Gender) = %s, want no interpretation", value)
+	}
+
+	// Predictions built in code have no raw values, they are encoded instead.
+	built := Predictions{Interpretations: []InterpretationEntry{{Trait: "Gender", Value: "female"}}}
+	if value, ok := built.InterpretationRaw("Gender"); !ok || string(value) != `"female"` {
+		t.Errorf("InterpretationRaw(Gender) of built predictions = %s, %v", value, ok)
+	}
+}
+
+func TestModelVersion(t *testing.T) {
+	responses := map[string]string{
+		`{"input_used":1}`:                           "",
+		`{"model_version":"v2"}`:                     "v2",
+		`{"model_version":7}`:                        "7",
+		`{"model_version":null,"version":"3.1"}`:     "3.1",
+		`{"model_version":"v2","modelVersion":"v1"}`: "v2",
+		`{"model":"m","version":3}`:                  "m",
+		`{"version":3}`:                              "3",
+	}
+	for response, want := range responses {
+		var predictions Predictions
+		if err := json.Unmarshal([]byte(response), &predictions); err != nil {
+			t.Errorf("%s: %v", response, err)
+			continue
+		}
+		if predictions.ModelVersion != want {
+			t.Errorf("%s: ModelVersion = %q, want %q", response, predictions.ModelVersion, want)
+		}
+	}
+
+	// The fields the version was taken from stay available.
+	var predictions Predictions
+	if err := json.Unmarshal([]byte(`{"model_version":"v2","modelVersion":"v1"}`), &predictions); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := predictions.Extra("modelVersion"); !ok || string(value) != `"v1"` {
+		t.Errorf("Extra(modelVersion) = %s, %v, want \"v1\"", value, ok)
+	}
+	if _, ok := predictions.Extra("model_version"); ok {
+		t.Error("model_version kept as an extra field")
+	}
+}
+
+func BenchmarkPredictionsUnmarshalJSON(b *testing.B) {
+	data := []byte(`{"input_used":120,"predictions":[{"trait":"BIG5_Openness","value":0.51},{"trait":"BIG5_Conscientiousness","value":0.42},{"trait":"BIG5_Extraversion","value":0.33},{"trait":"BIG5_Agreeableness","value":0.64},{"trait":"BIG5_Neuroticism","value":0.25}],"interpretations":[{"trait":"Age","value":27},{"trait":"Gender","value":"female"}],"contributors":[{"trait":"BIG5_Openness","positive":["1","2","3"],"negative":["4","5"]}]}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var predictions Predictions
+		if err := json.Unmarshal(data, &predictions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPredictionsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		predictions Predictions
+		valid       bool
+	}{
+		{"empty", Predictions{}, true},
+		{"valid", Predictions{
+			Predictions:  []PredictionEntry{{Trait: TraitOpenness, Value: 0.4}, {Trait: TraitAge, Value: 31}},
+			Contributors: []ContributorEntry{{Trait: TraitOpenness, Positive: []string{"1"}}},
+		}, true},
+		{"no trait", Predictions{Predictions: []PredictionEntry{{Value: 0.4}}}, false},
+		{"not a number", Predictions{Predictions: []PredictionEntry{{Trait: TraitOpenness, Value: math.NaN()}}}, false},
+		{"implausible", Predictions{Predictions: []PredictionEntry{{Trait: TraitAge, Value: -1e6}}}, false},
+		{"interpretation without trait", Predictions{Interpretations: []InterpretationEntry{{Value: "x"}}}, false},
+		{"empty like id", Predictions{Contributors: []ContributorEntry{{Trait: TraitOpenness, Negative: []string{"1", ""}}}}, false},
+	}
+	for _, test := range tests {
+		err := test.predictions.Validate()
+		if test.valid != (err == nil) || err != nil && !errors.Is(err, ErrInvalidPredictions) {
+			t.Errorf("%s: Validate() = %v", test.name, err)
+		}
+	}
+}
+
+func TestStrictResponses(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1,"predictions":[{"trait":"","value":0.5}]}`))
+	}
+	for _, strict := range []bool{false, true} {
+		client := newTestClient(t, handler, WithStrictResponses(strict))
+		predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+		if strict != errors.Is(err, ErrInvalidPredictions) {
+			t.Errorf("strict %t: err = %v", strict, err)
+		}
+		if predictions.InputUsed != 1 {
+			t.Errorf("strict %t: predictions not returned: %+v", strict, predictions)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	const response = `{"input_used":3,` +
+		`"predictions":[{"trait":"BIG5_Openness","value":0.5},{"trait":"Age","value":30},{"trait":"BIG5_Openness","value":0.6},{"trait":"BIG5_Openness","value":0.7}],` +
+		`"interpretations":[{"trait":"Gender","value":"female"},{"trait":"Gender","value":"male"}],` +
+		`"contributors":[{"trait":"Age","positive":["1"],"negative":[]},{"trait":"Age","positive":["2"],"negative":[]}]}`
+
+	var predictions Predictions
+	if err := json.Unmarshal([]byte(response), &predictions); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := predictions.Duplicates(), []string{"Age", "BIG5_Openness", "Gender"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Duplicates() = %v, want %v", got, want)
+	}
+
+	// The same trait in different lists is not a duplicate.
+	var unique Predictions
+	if err := json.Unmarshal([]byte(`{"predictions":[{"trait":"Age","value":30}],"interpretations":[{"trait":"Age","value":30}]}`), &unique); err != nil {
+		t.Fatal(err)
+	}
+	if got := unique.Duplicates(); got != nil {
+		t.Errorf("Duplicates() without duplicates = %v, want nil", got)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response))
+	}
+	for _, strict := range []bool{false, true} {
+		client := newTestClient(t, handler, WithStrictResponses(strict))
+		predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+		if strict != errors.Is(err, ErrDuplicateTraits) {
+			t.Errorf("strict %t: err = %v", strict, err)
+		}
+		if len(predictions.Predictions) != 4 {
+			t.Errorf("strict %t: predictions not returned: %+v", strict, predictions)
+		}
+	}
+}
+
+func TestMissingTraits(t *testing.T) {
+	requested := []string{TraitOpenness, TraitAge, TraitPolitics}
+	tests := []struct {
+		name        string
+		predictions []PredictionEntry
+		want        []string
+	}{
+		{"full", []PredictionEntry{{Trait: TraitOpenness}, {Trait: TraitAge}, {Trait: TraitPolitics + "_Liberal"}}, nil},
+		{"partial", []PredictionEntry{{Trait: TraitAge}}, []string{TraitOpenness, TraitPolitics}},
+		{"empty", nil, requested},
+		{"prefix of another trait", []PredictionEntry{{Trait: TraitOpenness}, {Trait: TraitAge}, {Trait: TraitPolitics + "Liberal"}}, []string{TraitPolitics}},
+	}
+	for _, test := range tests {
+		predictions := Predictions{Predictions: test.predictions, Requested: requested}
+		if got := predictions.MissingTraits(nil); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: MissingTraits(nil) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRequestedTraits(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1,"predictions":[{"trait":"Age","value":30}]}`))
+	})
+	options := PredictLikeIDsOptions([]string{TraitAge, TraitOpenness}, false, false)
+	predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, options, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{TraitOpenness}; !reflect.DeepEqual(predictions.MissingTraits(nil), want) {
+		t.Errorf("MissingTraits(nil) = %v with Requested %v, want %v", predictions.MissingTraits(nil), predictions.Requested, want)
+	}
+}
+
+func TestTraitDetail(t *testing.T) {
+	p := Predictions{
+		Predictions:     []PredictionEntry{{TraitOpenness, 0.7}, {TraitAge, 27}},
+		Interpretations: []InterpretationEntry{{TraitAge, "25-34"}, {TraitPolitics, "Liberal"}},
+	}
+	tests := []struct {
+		trait  string
+		want   TraitDetail
+		wantOK bool
+	}{
+		{TraitOpenness, TraitDetail{Value: 0.7, HasValue: true}, true},
+		{TraitPolitics, TraitDetail{Interpretation: "Liberal", HasInterpretation: true}, true},
+		{TraitAge, TraitDetail{Value: 27, Interpretation: "25-34", HasValue: true, HasInterpretation: true}, true},
+		{TraitReligion, TraitDetail{}, false},
+	}
+	for _, test := range tests {
+		if detail, ok := p.TraitDetail(test.trait); detail != test.want || ok != test.wantOK {
+			t.Errorf("TraitDetail(%s) = %+v, %v, want %+v, %v", test.trait, detail, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantEmpty bool
+	}{
+		{"no content", http.StatusNoContent, "", true},
+		{"empty predictions", http.StatusOK, `{"input_used":0,"predictions":[]}`, true},
+		{"input used without predictions", http.StatusOK, `{"input_used":3,"predictions":[]}`, false},
+		{"predictions", http.StatusOK, `{"input_used":3,"predictions":[{"trait":"BIG5_Openness","value":0.4}]}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+			predictions, err := client.PredictText(context.Background(), "text", MinimalBigFiveOptions(SourceOther), StubToken())
+			if err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+			if empty := predictions.IsEmpty(); empty != test.wantEmpty {
+				t.Errorf("IsEmpty() = %v, want %v", empty, test.wantEmpty)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	predictions := Predictions{
+		Predictions: []PredictionEntry{{TraitOpenness, 0.8}, {TraitAge, 31}, {TraitNeuroticism, 0.2}},
+		Interpretations: []InterpretationEntry{
+			{TraitOpenness, "liberal"}, {TraitNeuroticism, "calm"},
+		},
+		Contributors: []ContributorEntry{
+			{TraitOpenness, []string{"1"}, []string{"2"}}, {TraitAge, []string{"3"}, nil},
+		},
+	}
+	original := predictions.Clone()
+
+	tests := []struct {
+		name       string
+		filtered   Predictions
+		wantTraits []string
+	}{
+		{"above", predictions.Above(0.5), []string{TraitOpenness, TraitAge}},
+		{"below", predictions.Below(0.5), []string{TraitNeuroticism}},
+		{"by trait", predictions.Filter(func(trait string, _ float64) bool { return trait == TraitAge }), []string{TraitAge}},
+		{"none", predictions.Above(100), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var traits []string
+			for _, prediction := range test.filtered.Predictions {
+				traits = append(traits, prediction.Trait)
+			}
+			if !reflect.DeepEqual(traits, test.wantTraits) {
+				t.Errorf("traits = %v, want %v", traits, test.wantTraits)
+			}
+
+			kept := make(map[string]bool)
+			for _, trait := range traits {
+				kept[trait] = true
+			}
+			for _, interpretation := range test.filtered.Interpretations {
+				if !kept[interpretation.Trait] {
+					t.Errorf("interpretation of filtered out trait %s kept", interpretation.Trait)
+				}
+			}
+			for _, contributor := range test.filtered.Contributors {
+				if !kept[contributor.Trait] {
+					t.Errorf("contributors of filtered out trait %s kept", contributor.Trait)
+				}
+			}
+			for _, interpretation := range predictions.Interpretations {
+				if _, ok := test.filtered.Interpretation(interpretation.Trait); ok != kept[interpretation.Trait] {
+					t.Errorf("interpretation of %s kept = %v, want %v", interpretation.Trait, ok, kept[interpretation.Trait])
+				}
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(predictions, original) {
+		t.Errorf("Filter modified the predictions: %+v, want %+v", predictions, original)
+	}
+}
+
+func TestSorted(t *testing.T) {
+	predictions := Predictions{Predictions: []PredictionEntry{
+		{TraitOpenness, 0.5}, {TraitAgreeableness, 0.9}, {TraitNeuroticism, 0.5}, {TraitConscientiousness, 0.1},
+		{TraitExtraversion, 0.5},
+	}}
+
+	traits := func(entries []PredictionEntry) []string {
+		traits := make([]string, len(entries))
+		for i, entry := range entries {
+			traits[i] = entry.Trait
+		}
+		return traits
+	}
+	// Ties are ordered by name in both directions, whatever the order of the response.
+	ascending := []string{TraitConscientiousness, TraitExtraversion, TraitNeuroticism, TraitOpenness, TraitAgreeableness}
+	descending := []string{TraitAgreeableness, TraitExtraversion, TraitNeuroticism, TraitOpenness, TraitConscientiousness}
+	byTrait := []string{TraitAgreeableness, TraitConscientiousness, TraitExtraversion, TraitNeuroticism, TraitOpenness}
+
+	for i := 0; i < 10; i++ {
+		original := predictions.Clone()
+		if got := traits(predictions.SortedByValue(false)); !reflect.DeepEqual(got, ascending) {
+			t.Fatalf("SortedByValue(false) = %v, want %v", got, ascending)
+		}
+		if got := traits(predictions.SortedByValue(true)); !reflect.DeepEqual(got, descending) {
+			t.Fatalf("SortedByValue(true) = %v, want %v", got, descending)
+		}
+		if got := traits(predictions.SortedByTrait()); !reflect.DeepEqual(got, byTrait) {
+			t.Fatalf("SortedByTrait() = %v, want %v", got, byTrait)
+		}
+		if !reflect.DeepEqual(predictions, original) {
+			t.Fatalf("sorting modified the predictions")
+		}
+
+		// Shuffle the input to make sure the order does not depend on it.
+		entries := predictions.Predictions
+		entries[0], entries[i%len(entries)] = entries[i%len(entries)], entries[0]
+	}
+}
+
+func TestClone(t *testing.T) {
+	data := []byte(`{"input_used":2,"predictions":[{"trait":"BIG5_Openness","value":0.5}],"interpretations":[{"trait":"Political","value":{"Liberal":0.7,"Other":[0.1,0.2]}}],"contributors":[{"trait":"BIG5_Openness","positive":["1"],"negative":["2"]}]}`)
+	var original, want Predictions
+	if err := json.Unmarshal(data, &original); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := original.Clone()
+	if !reflect.DeepEqual(clone, original) {
+		t.Fatalf("Clone() = %+v, want %+v", clone, original)
+	}
+
+	clone.Predictions[0].Value = 0.9
+	clone.Contributors[0].Positive[0] = "3"
+	clone.Contributors[0].Negative[0] = "4"
+	political := clone.Interpretations[0].Value.(map[string]interface{})
+	political["Liberal"] = 0.1
+	political["Other"].([]interface{})[0] = 0.9
+	clone.Contributors = append(clone.Contributors, ContributorEntry{Trait: "Age"})
+
+	if !reflect.DeepEqual(original, want) {
+		t.Errorf("original modified through clone: %+v, want %+v", original, want)
+	}
+}
@@ -0,0 +1,50 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// PreparedLikeRequest is a set of Like IDs that has been encoded once by Client.PrepareLikeIDs for
+// repeated use with Client.PredictPrepared. It is immutable and therefore safe for concurrent use.
+type PreparedLikeRequest struct {
+	payload []byte
+	ids     []string
+}
+
+// PrepareLikeIDs encodes ids for use with PredictPrepared. This saves encoding the same IDs again on
+// every call, e.g. when predicting the same IDs with different tokens. The IDs are encoded with the
+// codec of the Client (see WithJSONCodec) and sent as they are, exactly like by PredictLikeIDs; use
+// NormalizeLikeIDs first to remove blanks and duplicates.
+func (c *Client) PrepareLikeIDs(ids []string) (*PreparedLikeRequest, error) {
+	payload, err := c.encodeLikeIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedLikeRequest{
+		payload: payload,
+		ids:     append([]string(nil), ids...),
+	}, nil
+}
+
+// Len returns the number of Like IDs in the request.
+func (r *PreparedLikeRequest) Len() int {
+	return len(r.ids)
+}
+
+// PredictPrepared works like PredictLikeIDs, but uses the Like IDs of a PreparedLikeRequest. The
+// results are the same, including Submitted, InputRef and ContributorsDropped. The encoded IDs can not
+// be split, so WithLikeIDBatching does not apply.
+func (c *Client) PredictPrepared(ctx context.Context, prepared *PreparedLikeRequest, options url.Values, auth *Token) (predictions Predictions, err error) {
+	if prepared == nil {
+		return predictions, fmt.Errorf("prepared request must not be nil")
+	}
+	if err = c.checkLikeIDCount(len(prepared.ids)); err != nil {
+		return predictions, err
+	}
+
+	ctx = c.withRequestID(ctx)
+	options = withDefaults(options, c.defaultLikeOptions)
+	return c.predictLikes(ctx, prepared.ids, prepared.payload, options, auth)
+}
@@ -0,0 +1,108 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPredictPrepared(t *testing.T) {
+	tests := []struct {
+		name             string
+		ids              []string
+		options          []ClientOption
+		wantContributors bool
+		wantDropped      bool
+		wantErr          bool
+	}{
+		{"contributors", []string{"1", "2", "3"}, []ClientOption{WithMinContributorIDs(3)}, true, false, false},
+		{"contributors dropped", []string{"1", "2"}, []ClientOption{WithMinContributorIDs(3)}, false, true, false},
+		{"too many ids", []string{"1", "2", "3"}, []ClientOption{WithMaxLikeIDs(2)}, false, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotBody string
+			var gotContributors bool
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				gotBody = string(body)
+				_, gotContributors = r.URL.Query()[OptionsContributors]
+				w.Write([]byte(`{"input_used":2}`))
+			}, test.options...)
+
+			prepared, err := client.PrepareLikeIDs(test.ids)
+			if err != nil {
+				t.Fatalf("PrepareLikeIDs: %v", err)
+			}
+			options := PredictLikeIDsOptions([]string{TraitOpenness}, false, true)
+			predictions, err := client.PredictPrepared(context.Background(), prepared, options, StubToken())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			preparedBody := gotBody
+
+			want, err := client.PredictLikeIDs(context.Background(), test.ids, options, StubToken())
+			if err != nil {
+				t.Fatalf("PredictLikeIDs: %v", err)
+			}
+			if !reflect.DeepEqual(predictions, want) {
+				t.Errorf("PredictPrepared = %#v, want the result of PredictLikeIDs %#v", predictions, want)
+			}
+			if preparedBody != gotBody {
+				t.Errorf("sent %s, PredictLikeIDs sends %s", preparedBody, gotBody)
+			}
+			if gotContributors != test.wantContributors {
+				t.Errorf("contributors requested: %v, want %v", gotContributors, test.wantContributors)
+			}
+			if predictions.ContributorsDropped != test.wantDropped || predictions.Submitted != len(test.ids) {
+				t.Errorf("ContributorsDropped = %v, Submitted = %d, want %v, %d", predictions.ContributorsDropped, predictions.Submitted, test.wantDropped, len(test.ids))
+			}
+		})
+	}
+}
+
+func BenchmarkPrepareLikeIDs(b *testing.B) {
+	client, err := NewClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = "1234567890123"
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.PrepareLikeIDs(ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type upperCodec struct{ stdCodec }
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	return bytes.ToUpper(data), err
+}
+
+func TestPrepareLikeIDsCodec(t *testing.T) {
+	client, err := NewClient(WithJSONCodec(upperCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared, err := client.PrepareLikeIDs([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("PrepareLikeIDs: %v", err)
+	}
+	if want, _ := client.encodeLikeIDs([]string{"a", "b"}); string(prepared.payload) != string(want) || string(want) != `["A","B"]` {
+		t.Errorf("payload = %s, want %s encoded with the codec of the Client", prepared.payload, want)
+	}
+}
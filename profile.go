@@ -0,0 +1,106 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultProfileContributors is the number of contributors per direction Client.AnalyzeProfile reports
+// for each trait, unless ProfileInput.Contributors is set.
+const DefaultProfileContributors = 5
+
+// ProfileInput is the input of Client.AnalyzeProfile. At least one of Text and LikeIDs is required.
+type ProfileInput struct {
+	// Text is a writing sample of the person and Source its kind (one of the Source constants).
+	// Source defaults to SourceOther.
+	Text   string
+	Source string
+
+	// LikeIDs are the Likes of the person.
+	LikeIDs []string
+
+	// Traits limits the predicted traits. All traits are predicted if it is empty.
+	Traits []string
+
+	// Contributors is the number of contributors per direction reported for each trait. It defaults
+	// to DefaultProfileContributors.
+	Contributors int
+}
+
+// Profile is a display-ready summary of the predictions for a person. See Client.AnalyzeProfile.
+type Profile struct {
+	// BigFive holds the five core personality traits, if HasBigFive is set.
+	BigFive    BigFive
+	HasBigFive bool
+
+	// Gender and Age are the interpretations of TraitFemale and TraitAge (see Predictions.Gender and
+	// Predictions.Age), if HasGender and HasAge are set.
+	Gender    GenderInterpretation
+	HasGender bool
+	Age       AgeInterpretation
+	HasAge    bool
+
+	// Contributors holds the top contributors per trait. Contributors are only available for Like
+	// IDs.
+	Contributors map[string]Contributors
+
+	// Predictions are the underlying predictions.
+	Predictions Predictions
+}
+
+// Contributors are the most influential positive and negative contributors of a trait, ordered by
+// their influence.
+type Contributors struct {
+	Positive []string
+	Negative []string
+}
+
+// AnalyzeProfile predicts the traits of a person and assembles them into a Profile. Interpretations
+// and contributors are always requested. If both a text and Like IDs are given, the predictions are
+// combined as described for Client.Analyze.
+func (c *Client) AnalyzeProfile(ctx context.Context, input ProfileInput, auth *Token) (profile Profile, err error) {
+	source := input.Source
+	if source == "" {
+		source = SourceOther
+	}
+	n := input.Contributors
+	if n == 0 {
+		n = DefaultProfileContributors
+	}
+
+	var predictions Predictions
+	switch {
+	case input.Text != "" && len(input.LikeIDs) > 0:
+		options := PredictLikeIDsOptions(input.Traits, true, true)
+		predictions, err = c.Analyze(ctx, input.Text, source, input.LikeIDs, options, auth)
+	case input.Text != "":
+		options := PredictTextOptions(source, input.Traits, true)
+		predictions, err = c.PredictText(ctx, input.Text, options, auth)
+	case len(input.LikeIDs) > 0:
+		options := PredictLikeIDsOptions(input.Traits, true, true)
+		predictions, err = c.PredictLikeIDs(ctx, input.LikeIDs, options, auth)
+	default:
+		return profile, fmt.Errorf("profile input needs a text or like ids")
+	}
+	if err != nil {
+		return profile, err
+	}
+
+	profile.Predictions = predictions
+	profile.BigFive, profile.HasBigFive = predictions.BigFive()
+	profile.Gender, profile.HasGender = predictions.Gender()
+	profile.Age, profile.HasAge = predictions.Age()
+
+	if len(predictions.Contributors) > 0 {
+		profile.Contributors = make(map[string]Contributors, len(predictions.Contributors))
+		for _, contributor := range predictions.Contributors {
+			positive, negative := predictions.TopContributors(contributor.Trait, n)
+			profile.Contributors[contributor.Trait] = Contributors{
+				Positive: positive,
+				Negative: negative,
+			}
+		}
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,72 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// profileFixture is a like_ids response with all Big Five traits, interpretations and contributors.
+const profileFixture = `{
+	"input_used": 6,
+	"predictions": [
+		{"trait": "BIG5_Openness", "value": 0.8},
+		{"trait": "BIG5_Conscientiousness", "value": 0.4},
+		{"trait": "BIG5_Extraversion", "value": 0.3},
+		{"trait": "BIG5_Agreeableness", "value": 0.6},
+		{"trait": "BIG5_Neuroticism", "value": 0.2},
+		{"trait": "Female", "value": 0.9},
+		{"trait": "Age", "value": 27.5}
+	],
+	"interpretations": [
+		{"trait": "Female", "value": "female"},
+		{"trait": "Age", "value": {"label": "25-34", "probability": 0.6}}
+	],
+	"contributors": [
+		{"trait": "BIG5_Openness", "positive": ["1", "2", "3"], "negative": ["4"]},
+		{"trait": "Age", "positive": [], "negative": ["5", "6"]}
+	]
+}`
+
+func TestAnalyzeProfile(t *testing.T) {
+	var query string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(profileFixture))
+	})
+
+	input := ProfileInput{LikeIDs: []string{"1", "2", "3", "4", "5", "6"}, Contributors: 2}
+	profile, err := client.AnalyzeProfile(context.Background(), input, StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "contributors=true&interpretations=true" {
+		t.Errorf("sent %q, want interpretations and contributors requested", query)
+	}
+
+	want := BigFive{Openness: 0.8, Conscientiousness: 0.4, Extraversion: 0.3, Agreeableness: 0.6, Neuroticism: 0.2}
+	if !profile.HasBigFive || profile.BigFive != want {
+		t.Errorf("BigFive = %+v (%t), want %+v", profile.BigFive, profile.HasBigFive, want)
+	}
+	if want := (GenderInterpretation{Label: "female", Confidence: 0.9}); !profile.HasGender || profile.Gender != want {
+		t.Errorf("Gender = %+v (%t), want %+v", profile.Gender, profile.HasGender, want)
+	}
+	if want := (AgeInterpretation{Label: "25-34", Confidence: 0.6}); !profile.HasAge || profile.Age != want {
+		t.Errorf("Age = %+v (%t), want %+v", profile.Age, profile.HasAge, want)
+	}
+	wantContributors := map[string]Contributors{
+		TraitOpenness: {Positive: []string{"1", "2"}, Negative: []string{"4"}},
+		TraitAge:      {Negative: []string{"5", "6"}},
+	}
+	if !reflect.DeepEqual(profile.Contributors, wantContributors) {
+		t.Errorf("Contributors = %v, want %v", profile.Contributors, wantContributors)
+	}
+	if profile.Predictions.InputUsed != 6 {
+		t.Errorf("InputUsed = %d, want 6", profile.Predictions.InputUsed)
+	}
+
+	if _, err := client.AnalyzeProfile(context.Background(), ProfileInput{}, StubToken()); err == nil {
+		t.Error("AnalyzeProfile accepted an empty input")
+	}
+}
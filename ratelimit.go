@@ -0,0 +1,161 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithClientSideRateLimit makes the Client throttle itself based on the UsageLimits of the Token, so
+// that it never exceeds the usage limit of the API. Calls to PredictLikeIDs and PredictText wait until
+// the usage limit allows another call or until the context is done.
+//
+// The limiter of every method is a token bucket: it starts with CallsAvailable calls and is refilled
+// at a rate of CallsLimit calls per CallsRenewalDays. If the limits are not renewed, a call fails as
+// soon as no calls are available. The limiter is reconfigured whenever the Token reports different
+// limits, e.g. after it has been renewed. Calls made since the previous limits that the new ones do not
+// account for yet are deducted again, see reconcileLimits.
+func WithClientSideRateLimit(enabled bool) ClientOption {
+	return func(c *Client) error {
+		if enabled {
+			c.rateLimiter = &rateLimiter{buckets: make(map[string]*bucket)}
+		} else {
+			c.rateLimiter = nil
+		}
+		return nil
+	}
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	// limits is the configuration the bucket was created from.
+	limits Limits
+
+	capacity float64
+	tokens   float64
+	// rate is the number of tokens added per second.
+	rate float64
+	last time.Time
+	// used is the number of tokens taken since the bucket was created.
+	used int
+}
+
+func newBucket(limits Limits, now time.Time) *bucket {
+	b := &bucket{
+		limits:   limits,
+		capacity: float64(limits.CallsLimit),
+		tokens:   float64(limits.CallsAvailable),
+		last:     now,
+	}
+	if limits.CallsRenewal && limits.CallsRenewalDays > 0 {
+		period := time.Duration(limits.CallsRenewalDays) * 24 * time.Hour
+		b.rate = float64(limits.CallsLimit) / period.Seconds()
+	}
+	return b
+}
+
+// reserve takes one token from the bucket and returns how long the caller has to wait before it may
+// use it. ok is false if the bucket will never have a token available.
+func (b *bucket) reserve(now time.Time) (wait time.Duration, ok bool) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.used++
+		return 0, true
+	}
+	if b.rate == 0 {
+		return 0, false
+	}
+
+	wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	b.tokens--
+	b.used++
+	return wait, true
+}
+
+// reconcileLimits returns fresh, the limits of a renewed token, with the calls in decrements (by
+// method) that were made while the old limits applied deducted, unless fresh already accounts for
+// them. Within the same period (same CallsAvailableSince) the server has seen the calls by which
+// CallsAvailable went down from old to fresh, so only the rest is deducted again. If the limits have
+// been reset since old (later CallsAvailableSince), the calls belong to the previous period and fresh
+// is taken as it is. Methods missing in old are taken as they are as well.
+func reconcileLimits(old, fresh []Limits, decrements map[string]int) []Limits {
+	reconciled := make([]Limits, len(fresh))
+	for i, limits := range fresh {
+		reconciled[i] = limits
+		for _, previous := range old {
+			if previous.Method != limits.Method || limits.CallsAvailableSince > previous.CallsAvailableSince {
+				continue
+			}
+			pending := decrements[limits.Method] - (previous.CallsAvailable - limits.CallsAvailable)
+			if pending > 0 {
+				reconciled[i].CallsAvailable -= pending
+				if reconciled[i].CallsAvailable < 0 {
+					reconciled[i].CallsAvailable = 0
+				}
+			}
+			break
+		}
+	}
+	return reconciled
+}
+
+// wait blocks until the usage limits of auth allow another call to endpoint. now is the current time
+// according to the clock of the Client and timer starts a timer on it (see WithTimer).
+func (r *rateLimiter) wait(ctx context.Context, endpoint string, auth *Token, now time.Time, timer func(time.Duration) (<-chan time.Time, func() bool)) error {
+	if auth == nil {
+		return nil
+	}
+
+	method := methodForEndpoint(endpoint)
+	limits, found := auth.UsageFor(method)
+	if !found || limits.CallsLimit <= 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("%d/%s", auth.CustomerID, method)
+
+	r.mu.Lock()
+	b, ok := r.buckets[key]
+	if !ok || b.limits != limits {
+		previous := b
+		b = newBucket(limits, now)
+		if ok {
+			reconciled := reconcileLimits([]Limits{previous.limits}, []Limits{limits}, map[string]int{method: previous.used})
+			b.tokens = float64(reconciled[0].CallsAvailable)
+		}
+		r.buckets[key] = b
+	}
+	wait, ok := b.reserve(now)
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("usage limit exhausted for %s", method)
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	expired, stop := timer(wait)
+	defer stop()
+	select {
+	case <-expired:
+		return nil
+	case <-ctx.Done():
+		r.mu.Lock()
+		b.tokens++
+		b.used--
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
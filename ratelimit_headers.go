@@ -0,0 +1,89 @@
+package applymagicsauce
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default names of the rate limit headers. See WithRateLimitHeaders.
+const (
+	DefaultRateLimitLimitHeader     = "X-RateLimit-Limit"
+	DefaultRateLimitRemainingHeader = "X-RateLimit-Remaining"
+	DefaultRateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimit is the rate limit the API reported in the headers of a response. See Client.LastRateLimit.
+//
+// Unlike the UsageLimits of a Token, which are a snapshot from the time of authentication, it is
+// updated with every response.
+type RateLimit struct {
+	// Limit is the maximum number of calls in the current period.
+	Limit int
+	// Remaining is the number of calls left in the current period.
+	Remaining int
+	// Reset is the time the current period ends. The header may contain either a unix timestamp in
+	// seconds or the number of seconds until the reset.
+	Reset time.Time
+}
+
+type rateLimitHeaders struct {
+	limit, remaining, reset string
+}
+
+// WithRateLimitHeaders sets the names of the headers Client.LastRateLimit is parsed from. The defaults
+// are DefaultRateLimitLimitHeader, DefaultRateLimitRemainingHeader and DefaultRateLimitResetHeader.
+func WithRateLimitHeaders(limit, remaining, reset string) ClientOption {
+	return func(c *Client) error {
+		if limit == "" || remaining == "" || reset == "" {
+			return fmt.Errorf("rate limit header names must not be empty")
+		}
+		c.rateLimitHeaders = rateLimitHeaders{limit, remaining, reset}
+		return nil
+	}
+}
+
+// LastRateLimit returns the rate limit reported by the most recent response that contained any of the
+// rate limit headers. ok is false if no response contained them yet.
+func (c *Client) LastRateLimit() (rateLimit RateLimit, ok bool) {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+	return c.lastRateLimit, c.hasRateLimit
+}
+
+func (c *Client) recordRateLimit(header http.Header, now time.Time) {
+	rateLimit, ok := parseRateLimit(header, c.rateLimitHeaders, now)
+	if !ok {
+		return
+	}
+
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+	c.lastRateLimit = rateLimit
+	c.hasRateLimit = true
+}
+
+// resetDeltaLimit separates the two formats of the reset header: smaller values are a number of
+// seconds, larger ones a unix timestamp.
+const resetDeltaLimit = 1000000000
+
+func parseRateLimit(header http.Header, names rateLimitHeaders, now time.Time) (rateLimit RateLimit, ok bool) {
+	if value, err := strconv.Atoi(header.Get(names.limit)); err == nil {
+		rateLimit.Limit = value
+		ok = true
+	}
+	if value, err := strconv.Atoi(header.Get(names.remaining)); err == nil {
+		rateLimit.Remaining = value
+		ok = true
+	}
+	if value, err := strconv.ParseInt(header.Get(names.reset), 10, 64); err == nil {
+		if value < resetDeltaLimit {
+			rateLimit.Reset = now.Add(time.Duration(value) * time.Second)
+		} else {
+			rateLimit.Reset = time.Unix(value, 0)
+		}
+		ok = true
+	}
+	return rateLimit, ok
+}
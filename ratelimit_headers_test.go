@@ -0,0 +1,62 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	names := rateLimitHeaders{DefaultRateLimitLimitHeader, DefaultRateLimitRemainingHeader, DefaultRateLimitResetHeader}
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   RateLimit
+		wantOK bool
+	}{
+		{"all headers with a delta", map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "42", "X-RateLimit-Reset": "60"},
+			RateLimit{Limit: 100, Remaining: 42, Reset: now.Add(time.Minute)}, true},
+		{"all headers with a timestamp", map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1583067600"},
+			RateLimit{Limit: 100, Remaining: 0, Reset: time.Unix(1583067600, 0)}, true},
+		{"only remaining", map[string]string{"X-RateLimit-Remaining": "7"}, RateLimit{Remaining: 7}, true},
+		{"missing headers", map[string]string{"Content-Type": "application/json"}, RateLimit{}, false},
+		{"malformed headers", map[string]string{"X-RateLimit-Limit": "many", "X-RateLimit-Reset": "soon"}, RateLimit{}, false},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		for key, value := range test.header {
+			header.Set(key, value)
+		}
+		got, ok := parseRateLimit(header, names, now)
+		if ok != test.wantOK || got.Limit != test.want.Limit || got.Remaining != test.want.Remaining || !got.Reset.Equal(test.want.Reset) {
+			t.Errorf("%s: parseRateLimit = %+v, %t, want %+v, %t", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestLastRateLimit(t *testing.T) {
+	var remaining string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if remaining != "" {
+			w.Header().Set("Calls-Remaining", remaining)
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithRateLimitHeaders("Calls-Limit", "Calls-Remaining", "Calls-Reset"))
+
+	for _, step := range []struct {
+		remaining string
+		want      int
+		wantOK    bool
+	}{{"", 0, false}, {"9", 9, true}, {"", 9, true}, {"8", 8, true}} {
+		remaining = step.remaining
+		if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := client.LastRateLimit(); ok != step.wantOK || got.Remaining != step.want {
+			t.Errorf("after remaining %q: LastRateLimit = %+v, %t, want %d, %t", step.remaining, got, ok, step.want, step.wantOK)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientSideRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	var requests int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"input_used":1}`))
+	}, append(clock.options(), WithClientSideRateLimit(true))...)
+
+	// 24 calls a day are one call per hour.
+	auth := StubToken()
+	auth.UsageLimits = []Limits{
+		{Method: MethodLikeIDs, CallsLimit: 24, CallsAvailable: 1, CallsRenewal: true, CallsRenewalDays: 1},
+		{Method: MethodText, CallsLimit: 5, CallsAvailable: 0},
+	}
+	predict := func(ctx context.Context) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := client.PredictLikeIDs(ctx, []string{"1"}, nil, auth)
+			done <- err
+		}()
+		return done
+	}
+
+	if err := <-predict(context.Background()); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	done := predict(context.Background())
+	if wait := clock.waitForTimer(t); wait != time.Hour {
+		t.Errorf("second call waits %s, want 1h", wait)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("%d requests sent before the wait is over, want 1", n)
+	}
+	clock.advance(time.Hour)
+	if err := <-done; err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done = predict(ctx)
+	clock.waitForTimer(t)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("canceled call: err = %v, want context.Canceled", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("%d requests sent, want 2", n)
+	}
+
+	// The canceled call gave its reservation back, so the next one waits an hour, not two.
+	done = predict(context.Background())
+	if wait := clock.waitForTimer(t); wait != time.Hour {
+		t.Errorf("call after the canceled one waits %s, want 1h", wait)
+	}
+	clock.advance(time.Hour)
+	<-done
+
+	if _, err := client.PredictText(context.Background(), "text", MinimalBigFiveOptions(SourceOther), auth); err == nil {
+		t.Error("PredictText succeeded without available calls and without renewal")
+	}
+}
+
+func TestReconcileLimits(t *testing.T) {
+	const period, nextPeriod = 1000, 2000
+	limits := func(method string, available int, since int64) Limits {
+		return Limits{Method: method, CallsLimit: 100, CallsAvailable: available, CallsAvailableSince: since}
+	}
+	tests := []struct {
+		name          string
+		old, fresh    Limits
+		decrements    int
+		wantAvailable int
+	}{
+		{"calls not seen by the server", limits(MethodText, 50, period), limits(MethodText, 50, period), 5, 45},
+		{"some calls seen", limits(MethodText, 50, period), limits(MethodText, 48, period), 5, 45},
+		{"all calls seen", limits(MethodText, 50, period), limits(MethodText, 45, period), 5, 45},
+		{"more calls seen", limits(MethodText, 50, period), limits(MethodText, 40, period), 5, 40},
+		{"reset", limits(MethodText, 2, period), limits(MethodText, 100, nextPeriod), 5, 100},
+		{"not below zero", limits(MethodText, 3, period), limits(MethodText, 3, period), 5, 0},
+		{"other method", limits(MethodLikeIDs, 50, period), limits(MethodText, 50, period), 5, 50},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fresh := []Limits{test.fresh}
+			reconciled := reconcileLimits([]Limits{test.old}, fresh, map[string]int{test.old.Method: test.decrements})
+			want := test.fresh
+			want.CallsAvailable = test.wantAvailable
+			if len(reconciled) != 1 || reconciled[0] != want {
+				t.Errorf("reconcileLimits = %+v, want %+v", reconciled, want)
+			}
+			if fresh[0] != test.fresh {
+				t.Error("the fresh limits were modified")
+			}
+		})
+	}
+}
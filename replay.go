@@ -0,0 +1,173 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ReplayMode controls how a Client with WithReplayDir uses the recorded responses.
+type ReplayMode int
+
+// Available replay modes.
+const (
+	// ReplayAuto replays recorded responses and records the response for every request that has none
+	// yet. This is the default.
+	ReplayAuto ReplayMode = iota
+	// ReplayOnly replays recorded responses and fails requests that have none, so the network is never
+	// used.
+	ReplayOnly
+	// ReplayRecord sends every request and records the response, replacing existing recordings.
+	ReplayRecord
+)
+
+// WithReplayDir makes the Client record the responses of the API to files in dir and replay them for
+// identical requests later on, e.g. to run tests or demos without network access. Requests are
+// identified by their CacheKey, i.e. by endpoint, options and payload. See WithReplayMode.
+//
+// ATTENTION: The recordings of the Auth endpoint contain valid authentication tokens.
+func WithReplayDir(dir string) ClientOption {
+	return func(c *Client) error {
+		c.replayDir = dir
+		return nil
+	}
+}
+
+// WithReplayMode sets the ReplayMode used with WithReplayDir. The default is ReplayAuto.
+func WithReplayMode(mode ReplayMode) ClientOption {
+	return func(c *Client) error {
+		if mode != ReplayAuto && mode != ReplayOnly && mode != ReplayRecord {
+			return fmt.Errorf("unknown replay mode: %d", mode)
+		}
+		c.replayMode = mode
+		return nil
+	}
+}
+
+// recording is the format of a recorded response on disk.
+type recording struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+type replayTransport struct {
+	dir  string
+	mode ReplayMode
+	next http.RoundTripper
+}
+
+func (t *replayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	payload, outgoing, err := replayPayload(request)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(t.dir, CacheKey(request.URL.Path, request.URL.Query(), payload)+".json")
+
+	if t.mode != ReplayRecord {
+		data, err := ioutil.ReadFile(path)
+		if err == nil || !os.IsNotExist(err) || t.mode == ReplayOnly {
+			// The request is not sent on, but a RoundTripper has to close its body anyway.
+			closeBody(outgoing)
+		}
+		switch {
+		case err == nil:
+			var rec recording
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return nil, fmt.Errorf("invalid recording %s: %v", path, err)
+			}
+			return &http.Response{
+				Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+				StatusCode:    rec.StatusCode,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        rec.Header,
+				Body:          ioutil.NopCloser(bytes.NewReader(rec.Body)),
+				ContentLength: int64(len(rec.Body)),
+				Request:       request,
+			}, nil
+		case !os.IsNotExist(err):
+			return nil, err
+		case t.mode == ReplayOnly:
+			return nil, fmt.Errorf("no recording for %s %s", request.Method, request.URL.Path)
+		}
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(recording{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The recordings may contain tokens, so only the owner may read them.
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	// WriteFile keeps the permissions of an existing file, e.g. of a recording made by an older version.
+	if err := os.Chmod(path, 0600); err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// replayPayload returns the payload of request without consuming its body, and the request to send
+// on. A RoundTripper must not modify the request, so the body is read from a copy obtained with
+// GetBody. Requests without GetBody are sent on as a clone with a body that replays the payload.
+func replayPayload(request *http.Request) (payload []byte, outgoing *http.Request, err error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, request, nil
+	}
+
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer body.Close()
+		payload, err = ioutil.ReadAll(body)
+		return payload, request, err
+	}
+
+	payload, err = ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	outgoing = request.Clone(request.Context())
+	outgoing.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	return payload, outgoing, nil
+}
+
+func closeBody(request *http.Request) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+}
@@ -0,0 +1,122 @@
+package applymagicsauce
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recordings")
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		text, _ := ioutil.ReadAll(r.Body)
+		if string(text) != "recorded" {
+			t.Errorf("body = %q, want the payload of the request", text)
+		}
+		w.Write([]byte(`{"input_used":7}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		mode         ReplayMode
+		text         string
+		wantRequests int
+		wantErr      bool
+	}{
+		{"record", ReplayAuto, "recorded", 1, false},
+		{"replay", ReplayAuto, "recorded", 0, false},
+		{"replay only", ReplayOnly, "recorded", 0, false},
+		{"replay only without recording", ReplayOnly, "other", 0, true},
+		{"record again", ReplayRecord, "recorded", 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(WithBaseURL(server.URL), WithReplayDir(dir), WithReplayMode(test.mode))
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+
+			requests = 0
+			predictions, err := client.PredictText(context.Background(), test.text, MinimalBigFiveOptions(SourceOther), StubToken())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if requests != test.wantRequests {
+				t.Errorf("%d requests sent, want %d", requests, test.wantRequests)
+			}
+			if err == nil && predictions.InputUsed != 7 {
+				t.Errorf("InputUsed = %d, want 7", predictions.InputUsed)
+			}
+		})
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("recordings = %v, %v, want one", files, err)
+	}
+	for _, path := range []string{dir, files[0]} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm&0077 != 0 {
+			t.Errorf("%s has mode %o, want it readable by the owner only", path, perm)
+		}
+	}
+}
+
+type recordingTransport struct {
+	body string
+}
+
+func (t *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, _ := ioutil.ReadAll(request.Body)
+	t.body = string(body)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}")), Request: request}, nil
+}
+
+func TestReplayTransportKeepsRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		getBody bool
+	}{
+		{"with GetBody", true},
+		{"without GetBody", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := &recordingTransport{}
+			transport := &replayTransport{dir: t.TempDir(), mode: ReplayRecord, next: next}
+
+			request, err := http.NewRequest(http.MethodPost, "http://localhost/text", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.getBody {
+				request.GetBody = nil
+				request.Body = ioutil.NopCloser(strings.NewReader("payload"))
+			}
+			body := request.Body
+
+			if _, err := transport.RoundTrip(request); err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			if next.body != "payload" {
+				t.Errorf("sent body %q, want the payload", next.body)
+			}
+			if request.Body != body {
+				t.Error("RoundTrip replaced the body of the request")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package applymagicsauce
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithRetry makes the Client retry failed requests up to maxAttempts times in total. The delay between
+// two attempts starts at initialDelay and doubles with every attempt. Requests are retried on network
+// errors and on the status codes 429, 500, 502, 503 and 504, unless WithRetryDecider is used. The
+// default is a single attempt.
+//
+// Retrying predictions may count against your usage limit more than once. See WithIdempotencyKey.
+func WithRetry(maxAttempts int, initialDelay time.Duration) ClientOption {
+	return func(c *Client) error {
+		if maxAttempts < 1 {
+			return fmt.Errorf("max attempts must be at least 1")
+		}
+		if initialDelay < 0 {
+			return fmt.Errorf("initial delay must not be negative")
+		}
+		c.maxAttempts = maxAttempts
+		c.retryDelay = initialDelay
+		return nil
+	}
+}
+
+// WithIdempotencyKey makes the Client send a random UUID in the Idempotency-Key header of every
+// request. All attempts of the same call share one key, so the API is able to recognize retried
+// requests.
+func WithIdempotencyKey(enabled bool) ClientOption {
+	return func(c *Client) error {
+		if enabled {
+			c.idempotencyKey = newUUID
+		} else {
+			c.idempotencyKey = nil
+		}
+		return nil
+	}
+}
+
+// WithIdempotencyKeyGenerator enables the Idempotency-Key header like WithIdempotencyKey, but uses
+// generate to create the keys. This is mostly useful to get deterministic keys in tests.
+func WithIdempotencyKeyGenerator(generate func() string) ClientOption {
+	return func(c *Client) error {
+		if generate == nil {
+			return fmt.Errorf("idempotency key generator must not be nil")
+		}
+		c.idempotencyKey = generate
+		return nil
+	}
+}
+
+// retryBudgetWindow is the period over which WithRetryBudget counts requests and retries.
+const retryBudgetWindow = 10
+
+// WithRetryBudget limits retries across all calls of the Client, so that a failing API does not cause
+// a retry storm. Within the last 10 seconds, retries may make up at most ratio of the requests (e.g.
+// 0.1 for 10%), plus minPerSec retries per second that are always allowed. Once the budget is used
+// up, failed calls return immediately instead of being retried until the budget refills.
+//
+// This option has no effect without WithRetry.
+func WithRetryBudget(ratio float64, minPerSec int) ClientOption {
+	return func(c *Client) error {
+		if ratio < 0 || minPerSec < 0 {
+			return fmt.Errorf("retry budget must not be negative")
+		}
+		c.retryBudget = &retryBudget{
+			ratio:     ratio,
+			minPerSec: minPerSec,
+		}
+		return nil
+	}
+}
+
+type retryBudget struct {
+	ratio     float64
+	minPerSec int
+
+	mu sync.Mutex
+	// slots count the requests and retries of the last retryBudgetWindow seconds, one slot per second.
+	slots [retryBudgetWindow]struct {
+		second   int64
+		requests int
+		retries  int
+	}
+}
+
+func (b *retryBudget) slot(now time.Time) int {
+	second := now.Unix()
+	i := int(second % retryBudgetWindow)
+	if b.slots[i].second != second {
+		b.slots[i].second = second
+		b.slots[i].requests = 0
+		b.slots[i].retries = 0
+	}
+	return i
+}
+
+// request records the first attempt of a call.
+func (b *retryBudget) request(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.slots[b.slot(now)].requests++
+}
+
+// retry reports whether another retry is within the budget and records it if it is.
+func (b *retryBudget) retry(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.slot(now)
+	var requests, retries int
+	for _, slot := range b.slots {
+		if slot.second > now.Unix()-retryBudgetWindow {
+			requests += slot.requests
+			retries += slot.retries
+		}
+	}
+
+	if float64(retries) >= b.ratio*float64(requests)+float64(b.minPerSec*retryBudgetWindow) {
+		return false
+	}
+	b.slots[current].retries++
+	return true
+}
+
+// backoff returns the delay before the next attempt after the given one.
+func (c *Client) backoff(attempt int) time.Duration {
+	return c.retryDelay << uint(attempt-1)
+}
+
+// WithRetryDecider replaces the built-in decision which failed requests WithRetry retries. decide is
+// called after every attempt but the last one, with either the response (its body can be read) or the
+// error of the transport. Requests are never retried once the context of the call is done,
+// independent of decide.
+func WithRetryDecider(decide func(resp *http.Response, err error) bool) ClientOption {
+	return func(c *Client) error {
+		if decide == nil {
+			return fmt.Errorf("retry decider must not be nil")
+		}
+		c.retryDecider = decide
+		return nil
+	}
+}
+
+func (c *Client) retryable(ctx context.Context, response *response, err error) bool {
+	var modifierErr *requestModifierError
+	if ctx.Err() != nil || errors.As(err, &modifierErr) {
+		return false
+	}
+	if c.retryDecider != nil {
+		var resp *http.Response
+		if response != nil {
+			resp = &http.Response{
+				Status:     fmt.Sprintf("%d %s", response.statusCode, http.StatusText(response.statusCode)),
+				StatusCode: response.statusCode,
+				Header:     response.header,
+				Body:       ioutil.NopCloser(bytes.NewReader(response.body)),
+			}
+		}
+		return c.retryDecider(resp, err)
+	}
+	if err != nil {
+		return true
+	}
+
+	switch response.statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(err)
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
@@ -0,0 +1,205 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingFirst returns a handler that answers every other request with 503, starting with the first,
+// and records the header key of all requests in got.
+func failingFirst(key string, got *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*got = append(*got, r.Header.Get(key))
+		if len(*got)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	var generated int
+	tests := []struct {
+		name   string
+		option ClientOption
+		check  func(key string) bool
+	}{
+		{"disabled", WithIdempotencyKey(false), func(key string) bool { return key == "" }},
+		{"random", WithIdempotencyKey(true), uuid.MatchString},
+		{"generator", WithIdempotencyKeyGenerator(func() string {
+			generated++
+			return fmt.Sprint("key-", generated)
+		}), regexp.MustCompile(`^key-[12]$`).MatchString},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var keys []string
+			client := newTestClient(t, failingFirst("Idempotency-Key", &keys), test.option, WithRetry(2, 0))
+			for i := 0; i < 2; i++ {
+				if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(keys) != 4 {
+				t.Fatalf("%d requests sent, want 4", len(keys))
+			}
+			for _, key := range keys {
+				if !test.check(key) {
+					t.Errorf("unexpected Idempotency-Key %q", key)
+				}
+			}
+			if keys[0] != keys[1] || keys[2] != keys[3] {
+				t.Errorf("keys %q differ between the attempts of a call", keys)
+			}
+			if keys[0] != "" && keys[0] == keys[2] {
+				t.Errorf("keys %q are the same for both calls", keys)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	clock := newFakeClock()
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, append(clock.options(), WithRetry(3, 0), WithRetryBudget(0.5, 0))...)
+
+	// Retries may make up half of the first attempts. Once they do, calls fail after their first
+	// attempt, until enough new calls have been made or the window has passed.
+	steps := []struct {
+		advance      time.Duration
+		wantRequests int
+	}{
+		{0, 2}, {0, 1}, {0, 2}, {0, 1}, {0, 2}, {0, 1},
+		{(retryBudgetWindow + 1) * time.Second, 2},
+	}
+	for i, step := range steps {
+		clock.advance(step.advance)
+		requests = 0
+		_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Attempts != step.wantRequests {
+			t.Errorf("call %d: err = %v, want %d attempts", i, err, step.wantRequests)
+		}
+		if requests != step.wantRequests {
+			t.Errorf("call %d: %d requests sent, want %d", i, requests, step.wantRequests)
+		}
+	}
+}
+
+func TestRetryDecider(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+		wantErr      bool
+	}{
+		{"503 retried", []int{503, 503, 200}, 3, false},
+		{"500 not retried", []int{500, 200}, 1, true},
+		{"429 not retried", []int{429, 200}, 1, true},
+		{"attempts exhausted", []int{503, 503, 503, 200}, 3, true},
+		// 0 closes the connection without a response.
+		{"transport error not retried", []int{0, 200}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The handler of a closed connection may still run when the call returns.
+			var mu sync.Mutex
+			var requests int
+			var decisions []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := test.statuses[requests]
+				requests++
+				mu.Unlock()
+				if status == 0 {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(`{"input_used":1}`))
+			}, WithRetry(3, 0), WithRetryDecider(func(resp *http.Response, err error) bool {
+				if resp == nil {
+					decisions = append(decisions, fmt.Sprint("error: ", err != nil))
+					return false
+				}
+				decisions = append(decisions, resp.Status)
+				return resp.StatusCode == http.StatusServiceUnavailable
+			}))
+
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+			mu.Lock()
+			defer mu.Unlock()
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if requests != test.wantRequests {
+				t.Errorf("%d requests sent, want %d (decisions %v)", requests, test.wantRequests, decisions)
+			}
+			if test.statuses[0] == 0 && (len(decisions) != 1 || decisions[0] != "error: true") {
+				t.Errorf("decisions = %v, want the decider to see the transport error", decisions)
+			}
+		})
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	tests := []struct {
+		name           string
+		closeLast      bool
+		wantStatusCode int
+	}{
+		{"persistent 500", false, http.StatusInternalServerError},
+		{"no response to the last retry", true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				last := requests == 4
+				mu.Unlock()
+				if last && test.closeLast {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+			}, WithRetry(4, 0))
+
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an *APIError", err)
+			}
+			if apiErr.Attempts != 4 || apiErr.LastStatus != http.StatusInternalServerError || apiErr.StatusCode != test.wantStatusCode {
+				t.Errorf("Attempts = %d, LastStatus = %d, StatusCode = %d; want 4, 500, %d",
+					apiErr.Attempts, apiErr.LastStatus, apiErr.StatusCode, test.wantStatusCode)
+			}
+			if !strings.Contains(err.Error(), "after 4 attempts, last status 500") {
+				t.Errorf("error %q does not report the attempts", err)
+			}
+			if test.closeLast && errors.Unwrap(err) == nil {
+				t.Error("error does not unwrap to the error of the last retry")
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package applymagicsauce
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencySmoothing is the weight of a new sample in the rolling average latency.
+const latencySmoothing = 0.1
+
+// Stats is a snapshot of the cumulative counters of a Client. See Client.Stats.
+type Stats struct {
+	// Requests is the total number of HTTP requests sent.
+	Requests int64
+	// EndpointRequests is the number of HTTP requests sent, per endpoint (e.g. EndpointText).
+	EndpointRequests map[string]int64
+	// Successes is the number of requests answered with a 2xx or 3xx status code.
+	Successes int64
+	// Errors is the number of requests that failed or were answered with a 4xx or 5xx status code.
+	Errors int64
+	// Retries is the number of requests that were retries of a failed one (see WithRetry).
+	Retries int64
+	// AverageLatency is an exponentially weighted moving average of the duration of the requests.
+	AverageLatency time.Duration
+}
+
+type stats struct {
+	requests  atomic.Int64
+	successes atomic.Int64
+	errors    atomic.Int64
+	retries   atomic.Int64
+
+	mu               sync.Mutex
+	endpointRequests map[string]int64
+	averageLatency   float64
+}
+
+func (s *stats) record(endpoint string, statusCode int, err error, latency time.Duration) {
+	s.requests.Add(1)
+	if err != nil || statusCode >= 400 {
+		s.errors.Add(1)
+	} else {
+		s.successes.Add(1)
+	}
+
+	endpoint = endpointPath(endpoint)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.endpointRequests == nil {
+		s.endpointRequests = make(map[string]int64)
+	}
+	s.endpointRequests[endpoint]++
+	if s.averageLatency == 0 {
+		s.averageLatency = float64(latency)
+	} else {
+		s.averageLatency += latencySmoothing * (float64(latency) - s.averageLatency)
+	}
+}
+
+// Stats returns a snapshot of the counters of all requests the Client has sent so far.
+func (c *Client) Stats() Stats {
+	snapshot := Stats{
+		Requests:  c.stats.requests.Load(),
+		Successes: c.stats.successes.Load(),
+		Errors:    c.stats.errors.Load(),
+		Retries:   c.stats.retries.Load(),
+	}
+
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	snapshot.EndpointRequests = make(map[string]int64, len(c.stats.endpointRequests))
+	for endpoint, count := range c.stats.endpointRequests {
+		snapshot.EndpointRequests[endpoint] = count
+	}
+	snapshot.AverageLatency = time.Duration(c.stats.averageLatency)
+	return snapshot
+}
+
+// metricsLabelEscaper escapes label values for the OpenMetrics text format.
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// OpenMetricsContentType is the content type of the output of Stats.WriteOpenMetrics, e.g. for the
+// Content-Type header of a /metrics endpoint.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// WriteOpenMetrics writes the snapshot to w in the OpenMetrics text format, e.g. to serve it on a
+// /metrics endpoint with the content type OpenMetricsContentType. All metrics are prefixed with
+// "applymagicsauce_"; the requests per endpoint are labeled with the endpoint. The counters are
+// families without the "_total" suffix that their samples have, as OpenMetrics requires.
+func (s Stats) WriteOpenMetrics(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	family := func(name, kind, unit, help string) {
+		fmt.Fprintf(buf, "# TYPE applymagicsauce_%s %s\n", name, kind)
+		if unit != "" {
+			fmt.Fprintf(buf, "# UNIT applymagicsauce_%s %s\n", name, unit)
+		}
+		fmt.Fprintf(buf, "# HELP applymagicsauce_%s %s\n", name, help)
+	}
+
+	family("requests", "counter", "", "Total number of HTTP requests sent.")
+	fmt.Fprintf(buf, "applymagicsauce_requests_total %d\n", s.Requests)
+
+	family("endpoint_requests", "counter", "", "Number of HTTP requests sent per endpoint.")
+	endpoints := make([]string, 0, len(s.EndpointRequests))
+	for endpoint := range s.EndpointRequests {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(buf, "applymagicsauce_endpoint_requests_total{endpoint=\"%s\"} %d\n",
+			metricsLabelEscaper.Replace(endpoint), s.EndpointRequests[endpoint])
+	}
+
+	family("successes", "counter", "", "Number of requests answered with a 2xx or 3xx status code.")
+	fmt.Fprintf(buf, "applymagicsauce_successes_total %d\n", s.Successes)
+
+	family("errors", "counter", "", "Number of requests that failed or were answered with a 4xx or 5xx status code.")
+	fmt.Fprintf(buf, "applymagicsauce_errors_total %d\n", s.Errors)
+
+	family("retries", "counter", "", "Number of requests that were retries of a failed one.")
+	fmt.Fprintf(buf, "applymagicsauce_retries_total %d\n", s.Retries)
+
+	family("average_latency_seconds", "gauge", "seconds", "Exponentially weighted moving average of the duration of the requests.")
+	fmt.Fprintf(buf, "applymagicsauce_average_latency_seconds %g\n", s.AverageLatency.Seconds())
+
+	buf.WriteString("# EOF\n")
+	return buf.Flush()
+}
@@ -0,0 +1,110 @@
+package applymagicsauce
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	stats := Stats{
+		Requests:         3,
+		EndpointRequests: map[string]int64{EndpointText: 2, `/a"b`: 1},
+		Successes:        2,
+		Errors:           1,
+		AverageLatency:   1500 * time.Millisecond,
+	}
+	var b strings.Builder
+	if err := stats.WriteOpenMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	output := b.String()
+
+	if !strings.HasSuffix(output, "\n# EOF\n") {
+		t.Errorf("output does not end with # EOF:\n%s", output)
+	}
+	for _, sample := range []string{
+		"applymagicsauce_requests_total 3\n",
+		`applymagicsauce_endpoint_requests_total{endpoint="/a\"b"} 1` + "\n",
+		`applymagicsauce_endpoint_requests_total{endpoint="` + EndpointText + `"} 2` + "\n",
+		"applymagicsauce_retries_total 0\n",
+		"applymagicsauce_average_latency_seconds 1.5\n",
+	} {
+		if !strings.Contains(output, sample) {
+			t.Errorf("output misses %q:\n%s", sample, output)
+		}
+	}
+
+	// Every sample must belong to the family declared before it: counters are declared without the
+	// _total suffix of their samples.
+	var family, kind string
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n# EOF\n"), "\n") {
+		if fields := strings.Fields(line); strings.HasPrefix(line, "# TYPE ") {
+			family, kind = fields[2], fields[3]
+			if strings.HasSuffix(family, "_total") {
+				t.Errorf("family %s has the _total suffix", family)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]
+		want := family
+		if kind == "counter" {
+			want += "_total"
+		}
+		if name != want {
+			t.Errorf("sample %s in family %s (%s)", name, family, kind)
+		}
+	}
+}
+
+// TestStatsConcurrent is meant to be run with -race.
+func TestStatsConcurrent(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointText {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithRetry(2, 0))
+
+	const calls = 16
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+				t.Errorf("PredictLikeIDs: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			client.PredictText(context.Background(), "text", MinimalBigFiveOptions(SourceOther), StubToken())
+			client.Stats()
+		}()
+	}
+	wg.Wait()
+
+	stats := client.Stats()
+	want := Stats{
+		Requests:         3 * calls,
+		EndpointRequests: map[string]int64{EndpointLikeIDs: calls, EndpointText: 2 * calls},
+		Successes:        calls,
+		Errors:           2 * calls,
+		Retries:          calls,
+	}
+	if stats.AverageLatency <= 0 {
+		t.Errorf("AverageLatency = %s, want a positive duration", stats.AverageLatency)
+	}
+	stats.AverageLatency = 0
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
@@ -0,0 +1,115 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// predictTextsConcurrency is the maximum number of concurrent requests made by Client.PredictTexts.
+const predictTextsConcurrency = 8
+
+// TextError is the error of a single text passed to PredictTexts.
+type TextError struct {
+	// Index is the index of the text in the texts passed to PredictTexts.
+	Index int
+	Err   error
+}
+
+func (e *TextError) Error() string {
+	return fmt.Sprintf("text %d: %v", e.Index, e.Err)
+}
+
+func (e *TextError) Unwrap() error {
+	return e.Err
+}
+
+// TextErrors is returned by PredictTexts if some of the texts failed. It holds one *TextError per
+// failed text, ordered by index.
+type TextErrors []*TextError
+
+func (e TextErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d texts failed: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the errors of the failed texts, so that errors.Is and errors.As look at all of them.
+func (e TextErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// PredictTexts predicts every text with PredictText, using the same options for all of them, with at
+// most a handful of requests in flight at a time. This suits many short texts, e.g. tweets, that are
+// scored separately.
+//
+// The returned predictions are aligned with texts. If some of the texts failed, their predictions are
+// empty and err is a TextErrors listing them; the predictions of the other texts are returned anyway.
+// Texts that were not yet sent when ctx is done fail with the error of ctx.
+func (c *Client) PredictTexts(ctx context.Context, texts []string, options url.Values, auth *Token) (predictions []Predictions, err error) {
+	predictions = make([]Predictions, len(texts))
+	errs := make([]error, len(texts))
+
+	// Every text is predicted with its own copy of the token, as the calls may renew it concurrently.
+	// Renewals are shared by the Client, so the token is renewed once.
+	tokens := make([]*Token, len(texts))
+
+	semaphore := make(chan struct{}, predictTextsConcurrency)
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(texts); j++ {
+				errs[j] = ctx.Err()
+			}
+		}
+		if errs[i] != nil {
+			break
+		}
+
+		tokens[i] = copyToken(auth)
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			predictions[i], errs[i] = c.PredictText(ctx, text, options, tokens[i])
+		}(i, text)
+	}
+	wg.Wait()
+	adoptRenewedToken(auth, tokens)
+
+	var failed TextErrors
+	for i, err := range errs {
+		if err != nil {
+			predictions[i] = Predictions{}
+			failed = append(failed, &TextError{Index: i, Err: err})
+		}
+	}
+	if len(failed) > 0 {
+		return predictions, failed
+	}
+	return predictions, nil
+}
+
+// adoptRenewedToken updates auth in place if one of the copies of it used by concurrent calls was
+// renewed, as it would have been by a single call.
+func adoptRenewedToken(auth *Token, copies []*Token) {
+	if auth == nil {
+		return
+	}
+	for _, token := range copies {
+		if token != nil && token.Token != auth.Token {
+			setToken(auth, token)
+			return
+		}
+	}
+}
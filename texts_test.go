@@ -0,0 +1,140 @@
+package applymagicsauce
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPredictTexts(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		text, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(string(text), "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"input_used":` + strconv.Itoa(len(text)) + `}`))
+	})
+
+	texts := []string{"a", "bad", "ccc", "bad", "eeeee"}
+	for i := 0; i < 3*predictTextsConcurrency; i++ {
+		texts = append(texts, "x")
+	}
+	predictions, err := client.PredictTexts(context.Background(), texts, MinimalBigFiveOptions(SourceTweet), StubToken())
+
+	var failed TextErrors
+	if !errors.As(err, &failed) {
+		t.Fatalf("err = %v, want TextErrors", err)
+	}
+	if len(failed) != 2 || failed[0].Index != 1 || failed[1].Index != 3 {
+		t.Errorf("failed texts = %v, want 1 and 3", failed)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("errors.As(err, *APIError) = %v, want status 400", apiErr)
+	}
+
+	if len(predictions) != len(texts) {
+		t.Fatalf("got %d predictions, want %d", len(predictions), len(texts))
+	}
+	for i, text := range texts {
+		want := len(text)
+		if strings.HasPrefix(text, "bad") {
+			want = 0
+		}
+		if predictions[i].InputUsed != want {
+			t.Errorf("predictions[%d].InputUsed = %d, want %d", i, predictions[i].InputUsed, want)
+		}
+	}
+}
+
+func TestPredictTextsCanceled(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent with a canceled context")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.PredictTexts(ctx, []string{"a", "b"}, MinimalBigFiveOptions(SourceTweet), StubToken())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+// TestPredictTextsRenewal is meant to be run with -race: all texts are rejected with the same token
+// at the same time and renew it concurrently.
+func TestPredictTextsRenewal(t *testing.T) {
+	var authCalls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			atomic.AddInt32(&authCalls, 1)
+			w.Write([]byte(`{"token":"renewed","customer_id":1,"expires":7258118400000}`))
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "renewed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithAutoRenew(true))
+
+	texts := make([]string, 4*predictTextsConcurrency)
+	for i := range texts {
+		texts[i] = "text " + strconv.Itoa(i)
+	}
+	auth := StubToken()
+	auth.CustomerID, auth.apiKey = 1, "key"
+
+	if _, err := client.PredictTexts(context.Background(), texts, MinimalBigFiveOptions(SourceTweet), auth); err != nil {
+		t.Fatalf("PredictTexts: %v", err)
+	}
+	if authCalls != 1 {
+		t.Errorf("token renewed %d times, want once", authCalls)
+	}
+	if auth.Token != "renewed" {
+		t.Errorf("auth.Token = %q, want the renewed token", auth.Token)
+	}
+}
+
+func TestTextPreprocessor(t *testing.T) {
+	var calls int32
+	stripSignature := func(text string) string {
+		atomic.AddInt32(&calls, 1)
+		if i := strings.Index(text, "\n-- \n"); i >= 0 {
+			return text[:i]
+		}
+		return text
+	}
+	var sent []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent = append(sent, string(body))
+		if len(sent) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithTextPreprocessor(stripSignature), WithRetry(2, 0))
+
+	predictions, err := client.PredictText(context.Background(), "Hi Bob\n-- \nAlice, Sales", MinimalBigFiveOptions(SourceEmail), StubToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[0] != "Hi Bob" || sent[1] != "Hi Bob" {
+		t.Errorf("sent %q, want the preprocessed text twice", sent)
+	}
+	if calls != 1 {
+		t.Errorf("preprocessor called %d times, want once per call", calls)
+	}
+	hash := sha256.Sum256([]byte("Hi Bob"))
+	if predictions.InputRef.TextHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("TextHash = %s, want the hash of the preprocessed text", predictions.InputRef.TextHash)
+	}
+}
@@ -0,0 +1,217 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Values of Limits.Method for the endpoints of the API. They are named after the endpoints.
+const (
+	MethodAuth    = "auth"
+	MethodText    = "text"
+	MethodLikeIDs = "like_ids"
+)
+
+// methodForEndpoint returns the Limits.Method that applies to endpoint (one of the Endpoint
+// constants).
+func methodForEndpoint(endpoint string) string {
+	switch endpointPath(endpoint) {
+	case EndpointAuth:
+		return MethodAuth
+	case EndpointText:
+		return MethodText
+	case EndpointLikeIDs:
+		return MethodLikeIDs
+	default:
+		return strings.TrimPrefix(endpointPath(endpoint), "/")
+	}
+}
+
+// UsageFor returns the usage limits of the Token for method (one of the Method constants, e.g.
+// MethodText for PredictText) and whether there are any.
+func (t *Token) UsageFor(method string) (limits Limits, ok bool) {
+	for _, l := range t.UsageLimits {
+		if l.Method == method {
+			return l, true
+		}
+	}
+	return Limits{}, false
+}
+
+// AvailableSince returns CallsAvailableSince, the time the limits were last reset, as time.Time. If it
+// is not positive, the zero time is returned.
+func (l Limits) AvailableSince() time.Time {
+	if l.CallsAvailableSince <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, l.CallsAvailableSince*int64(time.Millisecond))
+}
+
+// PermissionSet is a set of permissions, as granted by a Token.
+type PermissionSet map[string]struct{}
+
+// NewPermissionSet returns a PermissionSet containing the passed permissions.
+func NewPermissionSet(permissions ...string) PermissionSet {
+	set := make(PermissionSet, len(permissions))
+	for _, permission := range permissions {
+		set.Add(permission)
+	}
+	return set
+}
+
+// Add adds permission to the set.
+func (s PermissionSet) Add(permission string) {
+	s[permission] = struct{}{}
+}
+
+// Contains reports whether permission is in the set.
+func (s PermissionSet) Contains(permission string) bool {
+	_, ok := s[permission]
+	return ok
+}
+
+// Equal reports whether both sets contain exactly the same permissions.
+func (s PermissionSet) Equal(other PermissionSet) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for permission := range s {
+		if !other.Contains(permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalJSON decodes a Token and parses Expires, a unix timestamp in milliseconds, once. See
+// ExpiresAt.
+func (t *Token) UnmarshalJSON(data []byte) error {
+	return t.decodeWith(stdCodec{}, data)
+}
+
+// decodeWith decodes data like UnmarshalJSON, but with codec instead of encoding/json.
+func (t *Token) decodeWith(codec JSONCodec, data []byte) error {
+	// token has the fields but not the methods of Token, which avoids recursing into UnmarshalJSON.
+	type token Token
+	if err := codec.Unmarshal(data, (*token)(t)); err != nil {
+		return err
+	}
+	t.expiresAt = expiresAt(t.Expires)
+	return nil
+}
+
+// ExpiresAt returns the time the Token expires. For a decoded Token it is the Expires value sent by
+// the API, parsed at decode time, so later changes to Expires do not affect it. For a Token created
+// in code, Expires is parsed on every call. If Expires is not positive, the zero time is returned.
+func (t *Token) ExpiresAt() time.Time {
+	if !t.expiresAt.IsZero() {
+		return t.expiresAt
+	}
+	return expiresAt(t.Expires)
+}
+
+// IsExpired reports whether the Token has expired according to ExpiresAt. A Token without a valid
+// expiry counts as expired.
+func (t *Token) IsExpired() bool {
+	return t.expiredAt(time.Now())
+}
+
+// TokenExpired works like Token.IsExpired, but uses the clock of the Client (see WithClock).
+func (c *Client) TokenExpired(auth *Token) bool {
+	return auth.expiredAt(c.now())
+}
+
+func (t *Token) expiredAt(now time.Time) bool {
+	expires := t.ExpiresAt()
+	return expires.IsZero() || !now.Before(expires)
+}
+
+func expiresAt(expires int) time.Time {
+	if expires <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(expires)*int64(time.Millisecond))
+}
+
+// stubTokenValue is the token value of a StubToken, sent in the X-Auth-Token header.
+const stubTokenValue = "stub"
+
+// StubToken returns a Token that was not issued by the API, for use in tests and local development
+// against a mock server: it has the given permissions, the value "stub" and never expires in practice
+// (ExpiresAt is in the year 2200). The predict functions send it like any other Token, so no Auth call
+// is needed to drive them. The real API rejects it.
+func StubToken(permissions ...string) *Token {
+	expires := time.Date(2200, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return &Token{
+		Token:       stubTokenValue,
+		Expires:     int(expires.Unix() * 1000),
+		Permissions: append([]string(nil), permissions...),
+		expiresAt:   expires,
+	}
+}
+
+// PermissionSet returns the permissions of the Token as a PermissionSet.
+func (t *Token) PermissionSet() PermissionSet {
+	return NewPermissionSet(t.Permissions...)
+}
+
+// redactedTokenPrefix is the number of characters of the token value String shows.
+const redactedTokenPrefix = 4
+
+// String returns a summary of the Token that is safe to log: the token value itself is redacted to its
+// first few characters.
+func (t Token) String() string {
+	token := t.Token
+	if len(token) > redactedTokenPrefix {
+		token = token[:redactedTokenPrefix] + "..."
+	}
+
+	limits := make([]string, len(t.UsageLimits))
+	for i, l := range t.UsageLimits {
+		limits[i] = fmt.Sprintf("%s: %d/%d", l.Method, l.CallsAvailable, l.CallsLimit)
+	}
+
+	return fmt.Sprintf("Token{token: %q, customer: %d, expires: %d, permissions: [%s], usage limits: [%s]}",
+		token, t.CustomerID, t.Expires, strings.Join(t.Permissions, ", "), strings.Join(limits, ", "))
+}
+
+// GoString redacts the token value for the %#v verb as well. See String.
+func (t Token) GoString() string {
+	return t.String()
+}
+
+// ValidateToken asks the API whether auth is still accepted, e.g. before starting a large batch. The
+// API may revoke tokens before they expire. It returns false if the API rejects the token and an error
+// if the API could not be asked, e.g. because of a network error.
+//
+// The API has no dedicated endpoint for this, so ValidateToken sends an empty list of Like IDs to the
+// Like endpoint. This may count against the usage limit of the token. With WithDryRun nothing is sent
+// and every token is reported as valid.
+func (c *Client) ValidateToken(ctx context.Context, auth *Token) (valid bool, err error) {
+	if auth == nil {
+		return false, fmt.Errorf("token must not be nil")
+	}
+	if c.dryRun {
+		return true, nil
+	}
+
+	options := url.Values{}
+	options.Set(OptionsTraits, TraitOpenness)
+	response, err := c.doRequest(ctx, http.MethodPost, EndpointLikeIDs+"?"+options.Encode(), []byte("[]"), nil, auth)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case c.isAuthFailure(response.statusCode):
+		return false, nil
+	case response.statusCode >= http.StatusInternalServerError:
+		return false, fmt.Errorf("api is temporarily not available")
+	}
+	// Any other response, even an error about the empty input, means the token has been accepted.
+	return true, nil
+}
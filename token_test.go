@@ -0,0 +1,231 @@
+package applymagicsauce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []ClientOption
+		status    int
+		wantValid bool
+		wantErr   bool
+		wantCalls int
+	}{
+		{"accepted", nil, http.StatusOK, true, false, 1},
+		{"accepted with an error about the input", nil, http.StatusBadRequest, true, false, 1},
+		{"rejected", nil, http.StatusForbidden, false, false, 1},
+		{"unavailable", nil, http.StatusServiceUnavailable, false, true, 1},
+		{"dry run", []ClientOption{WithDryRun(true)}, http.StatusForbidden, true, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if token := r.Header.Get("X-Auth-Token"); token != stubTokenValue {
+					t.Errorf("X-Auth-Token = %q, want the token to validate", token)
+				}
+				w.WriteHeader(test.status)
+			}, test.options...)
+
+			valid, err := client.ValidateToken(context.Background(), StubToken())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if valid != test.wantValid {
+				t.Errorf("valid = %v, want %v", valid, test.wantValid)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("%d requests sent, want %d", calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTokenUnmarshalJSON(t *testing.T) {
+	// An auth response as sent by the API, with the expiry in milliseconds.
+	data := `{
+		"token": "4f9ace5a-0865-45d1-a067-47fde12e8bbf",
+		"customer_id": 2143,
+		"expires": 1791979200123,
+		"permissions": ["text", "like_ids"],
+		"usage_limits": [
+			{"method": "text", "callsLimit": 1000, "callsAvailable": 998, "callsAvailableSince": 1791892800000, "callsRenewal": true, "callsRenewalDays": 30}
+		]
+	}`
+	want := time.Date(2026, 10, 14, 12, 0, 0, 123*int(time.Millisecond), time.UTC)
+
+	var token Token
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		t.Fatal(err)
+	}
+	if expires := token.ExpiresAt(); !expires.Equal(want) {
+		t.Errorf("ExpiresAt = %s, want %s", expires, want)
+	}
+	if token.CustomerID != 2143 || len(token.UsageLimits) != 1 || token.UsageLimits[0].CallsAvailable != 998 {
+		t.Errorf("decoded %+v", token)
+	}
+
+	// The expiry is parsed once, when the token is decoded.
+	token.Expires = 0
+	if expires := token.ExpiresAt(); !expires.Equal(want) {
+		t.Errorf("ExpiresAt after changing Expires = %s, want %s", expires, want)
+	}
+
+	for _, expires := range []string{"0", "-1"} {
+		var token Token
+		if err := json.Unmarshal([]byte(`{"token":"t","expires":`+expires+`}`), &token); err != nil {
+			t.Fatal(err)
+		}
+		if !token.ExpiresAt().IsZero() || !token.IsExpired() {
+			t.Errorf("expires %s: ExpiresAt = %s, IsExpired = %v, want the zero time and expired", expires, token.ExpiresAt(), token.IsExpired())
+		}
+	}
+}
+
+func TestStubToken(t *testing.T) {
+	token := StubToken(MethodText, MethodLikeIDs)
+	if token.IsExpired() || token.ExpiresAt().Year() != 2200 {
+		t.Errorf("ExpiresAt = %s, want a far-future expiry", token.ExpiresAt())
+	}
+	if !token.PermissionSet().Contains(MethodText) || !token.PermissionSet().Contains(MethodLikeIDs) {
+		t.Errorf("permissions = %v", token.Permissions)
+	}
+	if len(StubToken().Permissions) != 0 {
+		t.Errorf("permissions without arguments = %v, want none", StubToken().Permissions)
+	}
+
+	var sent []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointAuth {
+			t.Error("Auth called for a stub token")
+		}
+		sent = append(sent, r.Header.Get("X-Auth-Token"))
+		w.Write([]byte(`{"input_used":1}`))
+	})
+	if _, err := client.PredictText(context.Background(), "text", MinimalBigFiveOptions(SourceOther), token); err != nil {
+		t.Errorf("PredictText: %v", err)
+	}
+	if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, token); err != nil {
+		t.Errorf("PredictLikeIDs: %v", err)
+	}
+	if want := []string{stubTokenValue, stubTokenValue}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent tokens %v, want %v", sent, want)
+	}
+}
+
+func TestPermissionSet(t *testing.T) {
+	set := (&Token{Permissions: []string{"text", "like_ids", "text"}}).PermissionSet()
+	for permission, want := range map[string]bool{"text": true, "like_ids": true, "auth": false, "": false} {
+		if set.Contains(permission) != want {
+			t.Errorf("Contains(%q) = %t, want %t", permission, !want, want)
+		}
+	}
+
+	tests := []struct {
+		other PermissionSet
+		want  bool
+	}{
+		{NewPermissionSet("like_ids", "text"), true},
+		{NewPermissionSet("text", "like_ids", "like_ids"), true},
+		{NewPermissionSet("text"), false},
+		{NewPermissionSet("text", "auth"), false},
+		{NewPermissionSet("text", "like_ids", "auth"), false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := set.Equal(test.other); got != test.want {
+			t.Errorf("Equal(%v) = %t, want %t", test.other, got, test.want)
+		}
+		if got := test.other.Equal(set); got != test.want {
+			t.Errorf("%v.Equal(set) = %t, want %t", test.other, got, test.want)
+		}
+	}
+	if !NewPermissionSet().Equal(nil) {
+		t.Error("an empty set does not equal nil")
+	}
+}
+
+func TestTokenRedacted(t *testing.T) {
+	const secret = "s3cr3t-t0k3n-value"
+	token := &Token{
+		Token:       secret,
+		CustomerID:  42,
+		Expires:     1600000000000,
+		Permissions: []string{"text"},
+		UsageLimits: []Limits{{Method: MethodText, CallsLimit: 100, CallsAvailable: 99}},
+	}
+
+	for _, format := range []string{"%v", "%+v", "%s", "%#v"} {
+		for _, v := range []interface{}{token, *token} {
+			got := fmt.Sprintf(format, v)
+			if strings.Contains(got, secret) {
+				t.Errorf("Sprintf(%q, %T) = %s, contains the token", format, v, got)
+			}
+			for _, want := range []string{`"s3cr..."`, "customer: 42", "expires: 1600000000000", "text: 99/100"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("Sprintf(%q, %T) = %s, want it to contain %s", format, v, got, want)
+				}
+			}
+		}
+	}
+
+	if got := (Token{Token: "abc"}).String(); !strings.Contains(got, `token: "abc"`) {
+		t.Errorf("String() = %s, want a short token as it is", got)
+	}
+}
+
+func TestPredictionsString(t *testing.T) {
+	predictions := Predictions{InputUsed: 3, Predictions: []PredictionEntry{{Trait: TraitOpenness, Value: 0.12345}, {Trait: TraitAge, Value: 31}}}
+	if got, want := fmt.Sprint(predictions), "Predictions{input used: 3, BIG5_Openness: 0.123, Age: 31}"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+func TestUsageLimitMethods(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	}, WithClientSideRateLimit(true))
+
+	calls := map[string]func(auth *Token) error{
+		MethodText: func(auth *Token) error {
+			_, err := client.PredictText(context.Background(), "text", MinimalBigFiveOptions(SourceOther), auth)
+			return err
+		},
+		MethodLikeIDs: func(auth *Token) error {
+			_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, auth)
+			return err
+		},
+	}
+
+	// Only the calls of the method without available calls fail.
+	for _, exhausted := range []string{MethodText, MethodLikeIDs} {
+		auth := StubToken()
+		auth.UsageLimits = []Limits{{Method: exhausted, CallsLimit: 10, CallsAvailable: 0}}
+		for method, call := range calls {
+			err := call(auth)
+			if failed := err != nil; failed != (method == exhausted) {
+				t.Errorf("%s exhausted: %s call err = %v", exhausted, method, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), exhausted) {
+				t.Errorf("%s exhausted: error %q does not name the method", exhausted, err)
+			}
+		}
+	}
+
+	for endpoint, want := range map[string]string{EndpointAuth: MethodAuth, EndpointText + "?source=OTHER": MethodText, EndpointLikeIDs: MethodLikeIDs} {
+		if method := methodForEndpoint(endpoint); method != want {
+			t.Errorf("methodForEndpoint(%q) = %q, want %q", endpoint, method, want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package applymagicsauce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Traits that can be requested with OptionsTraits, as listed in the technical documentation. Some of
+// them (e.g. TraitPolitics) make the API return several related predictions.
+const (
+	TraitOpenness          = "BIG5_Openness"
+	TraitConscientiousness = "BIG5_Conscientiousness"
+	TraitExtraversion      = "BIG5_Extraversion"
+	TraitAgreeableness     = "BIG5_Agreeableness"
+	TraitNeuroticism       = "BIG5_Neuroticism"
+	TraitLifeSatisfaction  = "Satisfaction_Life"
+	TraitIntelligence      = "Intelligence"
+	TraitAge               = "Age"
+	TraitFemale            = "Female"
+	TraitGay               = "Gay"
+	TraitLesbian           = "Lesbian"
+	TraitConcentration     = "Concentration"
+	TraitPolitics          = "Politics"
+	TraitReligion          = "Religion"
+	TraitRelationship      = "Relationship"
+)
+
+// builtinTraits are all Trait constants, in the order of their declaration.
+var builtinTraits = []string{
+	TraitOpenness,
+	TraitConscientiousness,
+	TraitExtraversion,
+	TraitAgreeableness,
+	TraitNeuroticism,
+	TraitLifeSatisfaction,
+	TraitIntelligence,
+	TraitAge,
+	TraitFemale,
+	TraitGay,
+	TraitLesbian,
+	TraitConcentration,
+	TraitPolitics,
+	TraitReligion,
+	TraitRelationship,
+}
+
+// bigFiveTraits are the traits of the Big Five personality model.
+var bigFiveTraits = []string{
+	TraitOpenness,
+	TraitConscientiousness,
+	TraitExtraversion,
+	TraitAgreeableness,
+	TraitNeuroticism,
+}
+
+var knownTraits = map[string]bool{
+	TraitOpenness:          true,
+	TraitConscientiousness: true,
+	TraitExtraversion:      true,
+	TraitAgreeableness:     true,
+	TraitNeuroticism:       true,
+	TraitLifeSatisfaction:  true,
+	TraitIntelligence:      true,
+	TraitAge:               true,
+	TraitFemale:            true,
+	TraitGay:               true,
+	TraitLesbian:           true,
+	TraitConcentration:     true,
+	TraitPolitics:          true,
+	TraitReligion:          true,
+	TraitRelationship:      true,
+}
+
+// SupportedTraits returns the traits that can be requested with OptionsTraits. If a path for
+// EndpointTraits is configured (see WithEndpoints), the list is fetched from the API. Otherwise, and
+// that is the default since the API has no such endpoint yet, the Trait constants of this package are
+// returned. The result can be passed to the option builders, e.g. PredictTextOptions.
+func (c *Client) SupportedTraits(ctx context.Context) ([]string, error) {
+	if c.endpoints.Traits == "" {
+		return append([]string(nil), builtinTraits...), nil
+	}
+
+	response, err := c.do(ctx, http.MethodGet, EndpointTraits, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accept both a plain list and an object holding the list.
+	var traits []string
+	if err = c.decode(EndpointTraits, response, &traits); err == nil {
+		return traits, nil
+	}
+	var wrapped struct {
+		Traits []string `json:"traits"`
+	}
+	if c.codec.Unmarshal(response.body, &wrapped) == nil && wrapped.Traits != nil {
+		return wrapped.Traits, nil
+	}
+	return nil, err
+}
+
+// TraitInfo holds human-readable information about a trait.
+type TraitInfo struct {
+	Name        string
+	Description string
+}
+
+// TraitDescriptions maps the Trait constants to human-readable information, e.g. for display in a UI.
+var TraitDescriptions = map[string]TraitInfo{
+	TraitOpenness: {
+		Name:        "Openness",
+		Description: "Openness to experience: curiosity, imagination and a preference for novelty and variety.",
+	},
+	TraitConscientiousness: {
+		Name:        "Conscientiousness",
+		Description: "A tendency to be organized, dependable and disciplined and to plan ahead.",
+	},
+	TraitExtraversion: {
+		Name:        "Extraversion",
+		Description: "A tendency to seek the company of others and to be outgoing, energetic and talkative.",
+	},
+	TraitAgreeableness: {
+		Name:        "Agreeableness",
+		Description: "A tendency to be cooperative, compassionate and trusting towards others.",
+	},
+	TraitNeuroticism: {
+		Name:        "Neuroticism",
+		Description: "A tendency to experience negative emotions such as anxiety, anger or sadness. Also called emotional instability.",
+	},
+	TraitLifeSatisfaction: {
+		Name:        "Life satisfaction",
+		Description: "How satisfied a person is with their life as a whole.",
+	},
+	TraitIntelligence: {
+		Name:        "Intelligence",
+		Description: "General cognitive ability.",
+	},
+	TraitAge: {
+		Name:        "Age",
+		Description: "The age of the person in years.",
+	},
+	TraitFemale: {
+		Name:        "Gender",
+		Description: "The probability that the person is female.",
+	},
+	TraitGay: {
+		Name:        "Gay",
+		Description: "The probability that a male person is homosexual.",
+	},
+	TraitLesbian: {
+		Name:        "Lesbian",
+		Description: "The probability that a female person is homosexual.",
+	},
+	TraitConcentration: {
+		Name:        "Concentration",
+		Description: "The field the person studies or has studied.",
+	},
+	TraitPolitics: {
+		Name:        "Political views",
+		Description: "The political orientation of the person.",
+	},
+	TraitReligion: {
+		Name:        "Religion",
+		Description: "The religious affiliation of the person.",
+	},
+	TraitRelationship: {
+		Name:        "Relationship status",
+		Description: "The relationship status of the person.",
+	},
+}
+
+// DescribeTrait returns the human-readable information about trait and whether there is any.
+func DescribeTrait(trait string) (info TraitInfo, ok bool) {
+	info, ok = TraitDescriptions[trait]
+	return info, ok
+}
+
+// UnknownTraitsError is returned if traits are requested that are not one of the Trait constants.
+type UnknownTraitsError struct {
+	Traits []string
+}
+
+func (e *UnknownTraitsError) Error() string {
+	return fmt.Sprintf("unknown traits: %s", strings.Join(e.Traits, ", "))
+}
+
+// ValidateTraits checks that all traits are one of the Trait constants. Otherwise an
+// *UnknownTraitsError listing the unknown traits is returned.
+func ValidateTraits(traits []string) error {
+	var unknown []string
+	for _, trait := range traits {
+		if !knownTraits[trait] {
+			unknown = append(unknown, trait)
+		}
+	}
+	if len(unknown) > 0 {
+		return &UnknownTraitsError{Traits: unknown}
+	}
+	return nil
+}
+
+// WithAllowUnknownTraits disables the validation of the requested traits against the Trait constants.
+// By default a Client refuses to send requests with unknown traits, because a typo silently leads to
+// missing predictions. Allow unknown traits if the API supports traits this package does not know yet.
+//
+// The package-level functions never validate the traits.
+func WithAllowUnknownTraits(allow bool) ClientOption {
+	return func(c *Client) error {
+		c.allowUnknownTraits = allow
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+package applymagicsauce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTraits(t *testing.T) {
+	tests := []struct {
+		name        string
+		traits      []string
+		wantUnknown []string
+	}{
+		{"valid", []string{TraitOpenness, TraitAge, TraitReligion}, nil},
+		{"typo", []string{TraitOpenness, "BIG5_Opennes", "age"}, []string{"BIG5_Opennes", "age"}},
+		{"none", nil, nil},
+	}
+	for _, test := range tests {
+		err := ValidateTraits(test.traits)
+		var unknown *UnknownTraitsError
+		if errors.As(err, &unknown) != (test.wantUnknown != nil) {
+			t.Fatalf("%s: err = %v, want unknown traits %v", test.name, err, test.wantUnknown)
+		}
+		if unknown != nil && !reflect.DeepEqual(unknown.Traits, test.wantUnknown) {
+			t.Errorf("%s: unknown traits = %v, want %v", test.name, unknown.Traits, test.wantUnknown)
+		}
+	}
+}
+
+func TestAllowUnknownTraits(t *testing.T) {
+	options := PredictLikeIDsOptions([]string{TraitOpenness, "BIG5_Opennes"}, false, false)
+	for _, allow := range []bool{false, true} {
+		var traits string
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			traits = r.URL.Query().Get(OptionsTraits)
+			w.Write([]byte(`{"input_used":1}`))
+		}, WithAllowUnknownTraits(allow))
+
+		_, err := client.PredictLikeIDs(context.Background(), []string{"1"}, options, StubToken())
+		var unknown *UnknownTraitsError
+		if errors.As(err, &unknown) == allow {
+			t.Errorf("allow %t: err = %v", allow, err)
+		}
+		if sent := traits != ""; sent != allow || allow && !strings.Contains(traits, "BIG5_Opennes") {
+			t.Errorf("allow %t: sent traits %q", allow, traits)
+		}
+	}
+}
+
+func TestDescribeTrait(t *testing.T) {
+	tests := []struct {
+		trait    string
+		wantName string
+		wantOK   bool
+	}{
+		{TraitOpenness, "Openness", true},
+		{TraitNeuroticism, "Neuroticism", true},
+		{"OPE", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		info, ok := DescribeTrait(test.trait)
+		if info.Name != test.wantName || ok != test.wantOK {
+			t.Errorf("DescribeTrait(%q) = %q, %v, want %q, %v", test.trait, info.Name, ok, test.wantName, test.wantOK)
+		}
+		if ok && info.Description == "" {
+			t.Errorf("DescribeTrait(%q) has no description", test.trait)
+		}
+	}
+
+	for _, trait := range builtinTraits {
+		if _, ok := DescribeTrait(trait); !ok {
+			t.Errorf("%s is not described", trait)
+		}
+	}
+}
+
+func TestSupportedTraits(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{"fallback", "", "", builtinTraits, false},
+		{"list", "/meta/traits", `["BIG5_Openness","Age","Future_Trait"]`, []string{TraitOpenness, TraitAge, "Future_Trait"}, false},
+		{"object", "/meta/traits", `{"traits":["Age"]}`, []string{TraitAge}, false},
+		{"invalid body", "/meta/traits", `<html></html>`, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requested []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requested = append(requested, r.Method+" "+r.URL.Path)
+				w.Write([]byte(test.body))
+			}, WithEndpoints(Endpoints{Traits: test.path}))
+
+			traits, err := client.SupportedTraits(context.Background())
+			if (err != nil) != test.wantErr || !reflect.DeepEqual(traits, test.want) {
+				t.Fatalf("SupportedTraits = %v, %v, want %v", traits, err, test.want)
+			}
+			if test.path == "" {
+				if len(requested) != 0 {
+					t.Errorf("fallback sent %v", requested)
+				}
+				// The fallback is a copy.
+				traits[0] = "changed"
+				if builtinTraits[0] == "changed" {
+					t.Error("changing the result changed the built-in traits")
+				}
+			} else if !reflect.DeepEqual(requested, []string{"GET " + test.path}) {
+				t.Errorf("requested %v, want GET %s", requested, test.path)
+			}
+		})
+	}
+}
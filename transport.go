@@ -0,0 +1,201 @@
+package applymagicsauce
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithProxy sends all requests through the HTTP proxy at proxyURL, instead of the proxy configured by
+// the environment (see http.ProxyFromEnvironment). It can not be combined with WithHTTPClient.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url: %v", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy url: %s", proxyURL)
+		}
+		c.proxyURL = u
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the Client's transport, e.g. to trust the certificate of
+// a mock server or to pin certificates. It can not be combined with WithHTTPClient.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) error {
+		if config == nil {
+			return fmt.Errorf("tls config must not be nil")
+		}
+		c.tlsConfig = config
+		return nil
+	}
+}
+
+// Timeouts of the transport of a Client. They limit the time until the API starts to answer, not the
+// time to read the response.
+const (
+	DefaultDialTimeout           = 10 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultResponseHeaderTimeout = 30 * time.Second
+)
+
+// WithResponseHeaderTimeout sets how long the Client waits for the headers of a response after sending
+// a request. The default is DefaultResponseHeaderTimeout. It can not be combined with WithHTTPClient.
+func WithResponseHeaderTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("response header timeout must be positive")
+		}
+		c.responseHeaderTimeout = timeout
+		return nil
+	}
+}
+
+// Defaults for the connection pool of a Client. All requests go to the same host, so the number of
+// idle connections per host is raised well above the default of net/http (2).
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// WithMaxIdleConns sets the maximum number of idle connections the Client keeps open in total. The
+// default is DefaultMaxIdleConns. It can not be combined with WithHTTPClient.
+func WithMaxIdleConns(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("max idle connections must be at least 1")
+		}
+		c.maxIdleConns = n
+		return nil
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the maximum number of idle connections the Client keeps open to the API.
+// Since all requests go to the same host, this is the setting that matters for a high throughput. The
+// default is DefaultMaxIdleConnsPerHost. It can not be combined with WithHTTPClient.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("max idle connections per host must be at least 1")
+		}
+		c.maxIdleConnsPerHost = n
+		return nil
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept open. The default is
+// DefaultIdleConnTimeout. It can not be combined with WithHTTPClient.
+func WithIdleConnTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("idle connection timeout must be positive")
+		}
+		c.idleConnTimeout = timeout
+		return nil
+	}
+}
+
+// setupTransport builds and wraps the transport of the http.Client as required by the options. It runs
+// after all options have been applied, so the order of the options does not matter. The http.Client is
+// copied, so an http.Client passed with WithHTTPClient is never modified.
+func (c *Client) setupTransport() error {
+	httpClient := *c.httpClient
+
+	if c.customHTTPClient {
+		if c.proxyURL != nil || c.tlsConfig != nil || c.maxIdleConns != 0 || c.maxIdleConnsPerHost != 0 || c.idleConnTimeout != 0 ||
+			c.responseHeaderTimeout != 0 {
+			return fmt.Errorf("transport options can not be combined with a custom http client")
+		}
+	} else {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = DefaultMaxIdleConns
+		transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+		transport.IdleConnTimeout = DefaultIdleConnTimeout
+		transport.DialContext = (&net.Dialer{
+			Timeout:   DefaultDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+		transport.ResponseHeaderTimeout = DefaultResponseHeaderTimeout
+		if c.responseHeaderTimeout != 0 {
+			transport.ResponseHeaderTimeout = c.responseHeaderTimeout
+		}
+		if c.maxIdleConns != 0 {
+			transport.MaxIdleConns = c.maxIdleConns
+		}
+		if c.maxIdleConnsPerHost != 0 {
+			transport.MaxIdleConnsPerHost = c.maxIdleConnsPerHost
+		}
+		if c.idleConnTimeout != 0 {
+			transport.IdleConnTimeout = c.idleConnTimeout
+		}
+		if c.proxyURL != nil {
+			transport.Proxy = http.ProxyURL(c.proxyURL)
+		}
+		if c.tlsConfig != nil {
+			transport.TLSClientConfig = c.tlsConfig.Clone()
+		}
+		httpClient.Transport = transport
+	}
+
+	if c.replayDir != "" {
+		httpClient.Transport = &replayTransport{
+			dir:  c.replayDir,
+			mode: c.replayMode,
+			next: httpClient.Transport,
+		}
+	}
+
+	c.httpClient = &httpClient
+	return nil
+}
+
+// Warmup opens a connection to the API, so that the first prediction does not have to wait for the
+// TCP and TLS handshakes. It sends a HEAD request to the base URL; the status of the response does not
+// matter. The connection stays in the pool of idle connections for the idle timeout (see
+// WithIdleConnTimeout).
+//
+// Warmup is safe for concurrent use. Calls while another one is running wait for it, and calls within
+// the idle timeout after a successful one return immediately. It does nothing with WithDryRun or
+// WithReplay.
+func (c *Client) Warmup(ctx context.Context) error {
+	if c.dryRun || c.replayDir != "" {
+		return nil
+	}
+
+	c.warmupMu.Lock()
+	defer c.warmupMu.Unlock()
+
+	idleTimeout := DefaultIdleConnTimeout
+	if c.idleConnTimeout != 0 {
+		idleTimeout = c.idleConnTimeout
+	}
+	if !c.warmAt.IsZero() && c.now().Sub(c.warmAt) < idleTimeout {
+		return nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, c.baseURL+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("could not warm up connection: %w", err)
+	}
+	// The body has to be read completely for the connection to be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	c.warmAt = c.now()
+	return nil
+}
@@ -0,0 +1,260 @@
+package applymagicsauce
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowHandler answers right away, but sends a large body in steps over about 300ms.
+func slowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"input_used":1,"predictions":[`))
+	for i := 0; i < 6; i++ {
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(strings.Repeat(`{"trait":"BIG5_Openness","value":0.5},`, 1000)))
+	}
+	w.Write([]byte(`{"trait":"Age","value":30}]}`))
+}
+
+func TestSlowResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ClientOption
+		wantErr error
+	}{
+		{"response header timeout", []ClientOption{WithResponseHeaderTimeout(100 * time.Millisecond)}, nil},
+		{"default deadline", []ClientOption{WithDefaultDeadline(100 * time.Millisecond)}, context.DeadlineExceeded},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, slowHandler, test.options...)
+			predictions, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && len(predictions.Predictions) != 6001 {
+				t.Errorf("got %d predictions, want 6001", len(predictions.Predictions))
+			}
+		})
+	}
+}
+
+func TestPackageClientTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { DefaultTimeout = timeout }(DefaultTimeout)
+	DefaultTimeout = time.Minute
+
+	client := packageClient()
+	if client.httpClient.Timeout != 0 {
+		t.Errorf("http.Client.Timeout = %s, want none", client.httpClient.Timeout)
+	}
+	if client.defaultDeadline != DefaultTimeout {
+		t.Errorf("default deadline = %s, want DefaultTimeout", client.defaultDeadline)
+	}
+}
+
+func TestConnectionPool(t *testing.T) {
+	tests := []struct {
+		name               string
+		options            []ClientOption
+		wantIdle           int
+		wantIdlePerHost    int
+		wantIdleConnTimout time.Duration
+		wantErr            bool
+	}{
+		{"defaults", nil, DefaultMaxIdleConns, DefaultMaxIdleConnsPerHost, DefaultIdleConnTimeout, false},
+		{"tuned", []ClientOption{WithMaxIdleConns(10), WithMaxIdleConnsPerHost(5), WithIdleConnTimeout(time.Second)}, 10, 5, time.Second, false},
+		{"invalid", []ClientOption{WithMaxIdleConnsPerHost(0)}, 0, 0, 0, true},
+		{"custom http client", []ClientOption{WithHTTPClient(&http.Client{}), WithMaxIdleConns(10)}, 0, 0, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(test.options...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewClient: %v, want error: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			transport := client.httpClient.Transport.(*http.Transport)
+			if transport.MaxIdleConns != test.wantIdle || transport.MaxIdleConnsPerHost != test.wantIdlePerHost ||
+				transport.IdleConnTimeout != test.wantIdleConnTimout {
+				t.Errorf("pool = %d, %d per host, %s; want %d, %d per host, %s",
+					transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout,
+					test.wantIdle, test.wantIdlePerHost, test.wantIdleConnTimout)
+			}
+		})
+	}
+}
+
+// BenchmarkConnectionPool sends bursts of concurrent predictions with the pool size of net/http and
+// the default of a Client. Connections beyond the idle pool are closed after every burst and opened
+// again for the next one.
+func BenchmarkConnectionPool(b *testing.B) {
+	const burst = 16
+	for _, perHost := range []int{2, DefaultMaxIdleConnsPerHost} {
+		b.Run(fmt.Sprint("idle per host ", perHost), func(b *testing.B) {
+			var connections int64
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(time.Millisecond)
+				w.Write([]byte(`{"input_used":1}`))
+			}))
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt64(&connections, 1)
+				}
+			}
+			server.Start()
+			defer server.Close()
+			client, err := NewClient(WithBaseURL(server.URL), WithMaxIdleConnsPerHost(perHost))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for j := 0; j < burst; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+				wg.Wait()
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&connections))/float64(b.N), "conns/burst")
+		})
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte(`{"input_used":1}`))
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient(WithBaseURL("http://api.invalid"), WithProxy(proxy.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+		t.Fatalf("PredictLikeIDs: %v", err)
+	}
+	if len(proxied) != 1 || !strings.HasPrefix(proxied[0], "http://api.invalid"+EndpointLikeIDs) {
+		t.Errorf("proxy received %q, want the request to the API", proxied)
+	}
+
+	for _, proxyURL := range []string{"localhost:3128", "://proxy", "/relative"} {
+		if _, err := NewClient(WithProxy(proxyURL)); err == nil {
+			t.Errorf("WithProxy(%q) accepted", proxyURL)
+		}
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"input_used":1}`))
+	}))
+	defer server.Close()
+	trusted := x509.NewCertPool()
+	trusted.AddCert(server.Certificate())
+
+	tests := []struct {
+		name    string
+		config  *tls.Config
+		wantErr bool
+	}{
+		{"system roots", nil, true},
+		{"trusted certificate", &tls.Config{RootCAs: trusted}, false},
+		{"skip verification", &tls.Config{InsecureSkipVerify: true}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := []ClientOption{WithBaseURL(server.URL)}
+			if test.config != nil {
+				options = append(options, WithTLSConfig(test.config))
+			}
+			client, err := NewClient(options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken())
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if test.config != nil && client.httpClient.Transport.(*http.Transport).TLSClientConfig == test.config {
+				t.Error("the transport uses the passed config instead of a copy")
+			}
+		})
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	var connections, heads int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"input_used":1}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	var trace *TraceInfo
+	client, err := NewClient(WithBaseURL(server.URL), WithHTTPTrace(true), WithObserver(func(event RequestEvent) {
+		trace = event.Trace
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Warmup(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt64(&heads); n != 1 {
+		t.Errorf("%d warmup requests, want 1", n)
+	}
+
+	if _, err := client.PredictLikeIDs(context.Background(), []string{"1"}, nil, StubToken()); err != nil {
+		t.Fatal(err)
+	}
+	if trace == nil || !trace.ReusedConn {
+		t.Errorf("trace = %+v, want the warm connection to be reused", trace)
+	}
+	if n := atomic.LoadInt64(&connections); n != 1 {
+		t.Errorf("%d connections opened, want 1", n)
+	}
+}